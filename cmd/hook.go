@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/acquisitionist/r-proc/rproc"
+)
+
+// runPostCommand runs post_run_command through the shell once the run has
+// ended, with a summary of the run in its environment, and logs its output
+// line by line. runErr is the error the run ended with, if any.
+func (app *application) runPostCommand(srv *rproc.Processor, runErr error) error {
+	status := "success"
+	switch {
+	case runErr != nil:
+		status = "failure"
+	case app.interrupted:
+		status = "interrupted"
+	}
+	output, err := filepath.Abs(app.config.Paths.Output)
+	if err != nil {
+		output = app.config.Paths.Output
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", app.config.PostRun)
+	} else {
+		cmd = exec.Command("sh", "-c", app.config.PostRun)
+	}
+	cmd.Env = append(os.Environ(),
+		"RPROC_STATUS="+status,
+		"RPROC_MATCHED="+strconv.FormatInt(srv.Matched(), 10),
+		"RPROC_LINES="+strconv.FormatInt(srv.Scanned(), 10),
+		"RPROC_OUTPUT="+output,
+	)
+	if runErr != nil {
+		cmd.Env = append(cmd.Env, "RPROC_ERROR="+runErr.Error())
+	}
+
+	app.logger.Info("running post-run command", "command", app.config.PostRun, "status", status)
+	out, err := cmd.CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		app.logger.Info("post-run command output", "line", scanner.Text())
+	}
+	if err != nil {
+		return fmt.Errorf("post-run command failed: %w", err)
+	}
+	app.logger.Info("post-run command finished")
+	return nil
+}
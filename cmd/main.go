@@ -1,101 +1,361 @@
-/*
-MIT License
-
-Copyright (c) 2025 The R-Proc Contributors
-
-Permission is hereby granted, free of charge, to any person obtaining a copy
-of this software and associated documentation files (the "Software"), to deal
-in the Software without restriction, including without limitation the rights
-to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
-copies of the Software, and to permit persons to whom the Software is
-furnished to do so, subject to the following conditions:
-
-The above copyright notice and this permission notice shall be included in all
-copies or substantial portions of the Software.
-
-THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
-IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
-FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
-AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
-LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
-OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
-SOFTWARE.
-*/
-
-package main
-
-import (
-	"flag"
-	"fmt"
-	"log/slog"
-	"os"
-	"runtime/debug"
-	"sync"
-
-	"github.com/go-playground/validator/v10"
-	"github.com/lmittmann/tint"
-	"gopkg.in/ini.v1"
-)
-
-func main() {
-	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelDebug}))
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Error(
-				"panic recovered",
-				"error", fmt.Sprintf("%v", r),
-				"trace", string(debug.Stack()),
-			)
-			os.Exit(1)
-		}
-	}()
-	if err := run(logger); err != nil {
-		logger.Error(err.Error(), "trace", string(debug.Stack()))
-		os.Exit(1)
-	}
-}
-
-type config struct {
-	Threads int `ini:"threads" validate:"required,gte=1"`
-
-	Paths struct {
-		Config string `validate:"required,file"`
-		Input  string `ini:"input" validate:"required,dir"`
-		Output string `ini:"output" validate:"required,dir"`
-	} `ini:"paths"`
-
-	Filter struct {
-		Field      string   `ini:"field" validate:"required,oneof=subreddit author title selftext body domain"`
-		Values     []string `ini:"values" validate:"required,dive,required"`
-		FileFilter string   `ini:"file_filter" validate:"required"`
-		MatchMode  string   `ini:"match_mode" validate:"required,oneof= exact partial regex"`
-	} `ini:"filters"`
-}
-
-type application struct {
-	config config
-	logger *slog.Logger
-	wg     sync.WaitGroup
-}
-
-func run(logger *slog.Logger) error {
-	var cfg config
-
-	flag.StringVar(&cfg.Paths.Config, "config", "config.ini", "Configuration file path")
-	flag.Parse()
-
-	v := validator.New(validator.WithRequiredStructEnabled())
-	ini, iniErr := ini.Load(cfg.Paths.Config)
-	if iniErr != nil {
-		return iniErr
-	}
-	mapErr := ini.MapTo(&cfg)
-	if mapErr != nil {
-		return mapErr
-	}
-	if cfgErr := v.Struct(cfg); cfgErr != nil {
-		return cfgErr
-	}
-	app := application{config: cfg, logger: logger}
-	return app.serveProcessor()
-}
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acquisitionist/r-proc/rproc"
+	"github.com/lmittmann/tint"
+	"gopkg.in/ini.v1"
+)
+
+func main() {
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelDebug}))
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(
+				"panic recovered",
+				"error", fmt.Sprintf("%v", r),
+				"trace", string(debug.Stack()),
+			)
+			os.Exit(1)
+		}
+	}()
+	if err := run(logger); err != nil {
+		logger.Error(err.Error(), "trace", string(debug.Stack()))
+		if errors.Is(err, rproc.ErrDiskFull) {
+			os.Exit(exitDiskFull)
+		}
+		os.Exit(1)
+	}
+}
+
+// exitDiskFull is the exit status when the run stopped because the output
+// disk filled up, as opposed to 1 for any other error.
+const exitDiskFull = 3
+
+type config struct {
+	Threads     int           `ini:"threads" validate:"gte=0"`
+	FailFast    bool          `ini:"fail_fast"`
+	MaxRuntime  time.Duration `ini:"max_runtime" validate:"gte=0"`
+	DecodeMB    int64         `ini:"concurrent_decode_mb" validate:"gte=0"`
+	ChunkMB     int64         `ini:"seekable_chunk_mb" validate:"gte=0"`
+	ReadRate    int64         `ini:"read_rate_limit" validate:"gte=0"`
+	PostRun     string        `ini:"post_run_command"`
+	PostRunFail bool          `ini:"post_run_on_failure"`
+	ResetDedupe bool          `ini:"-"`
+	ProfileMem  string        `ini:"-"`
+	Yes         bool          `ini:"-"`
+	ListFields  int           `ini:"-" validate:"gte=0"`
+	ListNested  bool          `ini:"-"`
+	FieldLens   int           `ini:"-" validate:"gte=0"`
+	QuietErrors int           `ini:"-" validate:"gte=0"`
+	BenchLines  int           `ini:"-" validate:"gte=0"`
+	PrintConfig bool          `ini:"-"`
+	SelfTest    bool          `ini:"-"`
+	Watch       bool          `ini:"-"`
+	WatchEvery  time.Duration `ini:"-" validate:"gte=0"`
+	Offset      int64         `ini:"-" validate:"gte=0"`
+	Length      int64         `ini:"-" validate:"gte=0"`
+	OTel        string        `ini:"-" validate:"omitempty,http_url"`
+
+	Paths struct {
+		Config []string `ini:"-" validate:"required,dive,file|eq=-"`
+		Input  string   `ini:"input" validate:"required_without=Files,omitempty,dir|http_url"`
+		Files  []string `ini:"-" validate:"dive,file|http_url"`
+		Output string   `ini:"output" validate:"required,dir"`
+		Dedupe string   `ini:"dedupe_store"`
+		Follow bool     `ini:"follow_symlinks"`
+		Dict   string   `ini:"zstd_dict" validate:"omitempty,file"`
+	} `ini:"paths"`
+
+	Filter struct {
+		Fields      []string            `ini:"field" validate:"required,dive,oneof=subreddit author title selftext body domain score num_comments created_utc all_awardings __filename"`
+		Values      []string            `ini:"values" validate:"required_unless=MatchMode extract MatchMode aggregate,dive,required"`
+		ValuesCSV   string              `ini:"values_csv" validate:"omitempty,file"`
+		CSVColumn   int                 `ini:"values_csv_column" validate:"gte=0"`
+		CSVHeader   bool                `ini:"values_csv_header"`
+		FileFilter  string              `ini:"file_filter" validate:"required"`
+		Extensions  []string            `ini:"input_extensions" validate:"required,dive,required"`
+		MatchMode   string              `ini:"match_mode" validate:"required,oneof=exact partial regex type word jq array_len extract aggregate"`
+		Unescape    bool                `ini:"html_unescape"`
+		Aliases     map[string][]string `ini:"-"`
+		EmptyField  string              `ini:"empty_field" validate:"oneof=skip count match"`
+		RegexFold   bool                `ini:"regex_case_insensitive"`
+		Combine     bool                `ini:"combine_values"`
+		ExtractMax  int                 `ini:"extract_max_values" validate:"gte=0"`
+		Bucket      float64             `ini:"aggregate_bucket" validate:"gte=0"`
+		DedupeField string              `ini:"dedupe_field"`
+		DedupeClose bool                `ini:"dedupe_on_close"`
+		BlockFields []string            `ini:"block_field" validate:"required_with=BlockValues,dive,oneof=subreddit author title selftext body domain score num_comments created_utc all_awardings __filename"`
+		BlockValues []string            `ini:"block_values" validate:"dive,required"`
+		BlockMode   string              `ini:"block_match_mode" validate:"oneof=exact partial regex type word jq array_len"`
+		TimeField   string              `ini:"time_field" validate:"required"`
+		TimeStart   string              `ini:"time_start"`
+		TimeEnd     string              `ini:"time_end"`
+		NumberField string              `ini:"number_field" validate:"required"`
+		NumberMin   string              `ini:"number_min" validate:"omitempty,numeric"`
+		NumberMax   string              `ini:"number_max" validate:"omitempty,numeric"`
+		HashSample  int64               `ini:"hash_sample" validate:"gte=0"`
+		HashField   string              `ini:"hash_sample_field" validate:"required"`
+		HashSalt    string              `ini:"hash_sample_salt"`
+	} `ini:"filters"`
+
+	Output struct {
+		AnnotateSource bool   `ini:"annotate_source"`
+		CountOnly      bool   `ini:"count_only"`
+		DebugSample    int    `ini:"debug_sample" validate:"gte=0"`
+		Checkpoint     int64  `ini:"checkpoint_interval" validate:"gte=0"`
+		Progress       int    `ini:"progress_interval" validate:"gte=0"`
+		Report         bool   `ini:"run_report"`
+		ProgressMode   string `ini:"progress_mode" validate:"omitempty,oneof=bars compact"`
+
+		Scrub            []string `ini:"-"`
+		ScrubPlaceholder string   `ini:"scrub_placeholder"`
+
+		Compute []computeField `ini:"-"`
+
+		SortField       string `ini:"output_sort_field"`
+		SortBufferLines int    `ini:"sort_buffer_lines" validate:"gte=0"`
+		QueueDepth      int    `ini:"output_queue_depth" validate:"gte=0"`
+		IOThreads       int    `ini:"io_threads" validate:"gte=0"`
+		InvalidUTF8     string `ini:"invalid_utf8" validate:"omitempty,oneof=keep skip repair"`
+		LineEnding      string `ini:"output_line_ending" validate:"omitempty,oneof=lf crlf"`
+		Layout          string `ini:"output_layout" validate:"omitempty,oneof=flat by-value"`
+		Shards          int    `ini:"output_shards" validate:"gte=0"`
+		GroupBy         string `ini:"group_by"`
+		TimeHistogram   string `ini:"time_histogram" validate:"omitempty,oneof=hour day week month year"`
+		Atomic          bool   `ini:"atomic_output"`
+		Append          bool   `ini:"output_append"`
+		Validate        bool   `ini:"validate_output"`
+		Trim            bool   `ini:"trim_output"`
+		Flatten         bool   `ini:"output_flatten"`
+		MaxBytes        int64  `ini:"max_output_bytes" validate:"gte=0"`
+		FileMode        string `ini:"output_file_mode"`
+		Chmod           bool   `ini:"output_chmod"`
+		Checksums       bool   `ini:"output_checksums"`
+		Compression     string `ini:"output_compression" validate:"omitempty,oneof=none zstd auto"`
+		StreamURL       string `ini:"stream_url" validate:"omitempty,http_url"`
+		Ordered         bool   `ini:"ordered_output"`
+	} `ini:"output"`
+}
+
+// computeField is an entry of the output_compute section, parsed into an
+// rproc.ComputedField when the processor is set up.
+type computeField struct {
+	Name string
+	Expr string
+}
+
+type application struct {
+	config config
+	logger *slog.Logger
+	wg     sync.WaitGroup
+
+	// interrupted is set when the run was stopped by a signal or by
+	// max_runtime before it finished.
+	interrupted bool
+}
+
+func run(logger *slog.Logger) error {
+	var cfg config
+
+	cfg.Paths.Config = []string{"config.ini"}
+	flag.Func("config", "Comma-separated configuration files, later ones overriding earlier ones, or - to read it from stdin (default config.ini)", func(s string) error {
+		cfg.Paths.Config = strings.Split(s, ",")
+		for i, path := range cfg.Paths.Config {
+			cfg.Paths.Config[i] = strings.TrimSpace(path)
+		}
+		return nil
+	})
+	flag.BoolVar(&cfg.ResetDedupe, "reset-dedupe", false, "Clear the persistent dedupe store before processing")
+	flag.BoolVar(&cfg.Yes, "yes", false, "Start processing without asking for confirmation")
+	flag.StringVar(&cfg.ProfileMem, "profile-mem", "", "Write a heap profile to this path when the run completes")
+	flag.IntVar(&cfg.QuietErrors, "quiet-errors", 0, "Log at most this many identical warnings or errors and summarise the rest at the end (0 logs all)")
+	flag.IntVar(&cfg.BenchLines, "bench", 0, "Sample this many lines of each input file and print the matching speed of each match mode instead of processing")
+	flag.IntVar(&cfg.ListFields, "list-fields", 0, "Sample this many lines of each input file and print the fields found instead of processing")
+	flag.IntVar(&cfg.FieldLens, "field-lengths", 0, "Sample this many lines of each input file and print percentiles of the field and line lengths instead of processing")
+	flag.BoolVar(&cfg.ListNested, "list-nested", false, "Include nested object keys as dotted paths with -list-fields")
+	flag.BoolVar(&cfg.Watch, "watch", false, "Keep running after processing and process new files that appear in the input directory")
+	flag.DurationVar(&cfg.WatchEvery, "watch-interval", 10*time.Second, "How often -watch scans the input directory")
+	flag.Int64Var(&cfg.Offset, "offset", 0, "Only process lines starting at or after this byte offset of each decompressed input")
+	flag.Int64Var(&cfg.Length, "length", 0, "Only process lines starting within this many bytes after -offset (0 reads to the end)")
+	flag.StringVar(&cfg.OTel, "otel-endpoint", "", "Send OpenTelemetry trace spans to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	flag.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the resolved configuration in ini format and exit")
+	flag.BoolVar(&cfg.SelfTest, "generate-test-data", false, "Process a generated synthetic dataset to check the installation, without reading the config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if cfg.SelfTest {
+		return selfTest(logger)
+	}
+	if flag.NArg() > 0 {
+		cfg.Paths.Files = flag.Args()
+	}
+
+	v := newValidator()
+	sources, err := configSources(cfg.Paths.Config)
+	if err != nil {
+		return err
+	}
+	cfg.Filter.TimeField = "created_utc"
+	cfg.Filter.HashField = "id"
+	cfg.Filter.NumberField = "score"
+	cfg.Filter.BlockMode = "exact"
+	cfg.Filter.EmptyField = "skip"
+	cfg.Filter.Extensions = []string{".zst", ".ndjson", ".jsonl", ".json"}
+	cfg.Output.ScrubPlaceholder = "[REDACTED]"
+	cfg.Output.Append = true
+
+	// Keys in later files override those in earlier ones.
+	ini, iniErr := ini.Load(sources[0], sources[1:]...)
+	if iniErr != nil {
+		return iniErr
+	}
+	mapErr := ini.MapTo(&cfg)
+	if mapErr != nil {
+		return mapErr
+	}
+	for _, key := range ini.Section("output_scrub").Keys() {
+		cfg.Output.Scrub = append(cfg.Output.Scrub, key.String())
+	}
+	for _, key := range ini.Section("output_compute").Keys() {
+		cfg.Output.Compute = append(cfg.Output.Compute, computeField{Name: key.Name(), Expr: key.String()})
+	}
+	for _, key := range ini.Section("field_aliases").Keys() {
+		if cfg.Filter.Aliases == nil {
+			cfg.Filter.Aliases = make(map[string][]string)
+		}
+		cfg.Filter.Aliases[key.Name()] = key.Strings(",")
+	}
+	if cfg.Filter.ValuesCSV != "" {
+		values, err := loadCSVValues(cfg.Filter.ValuesCSV, cfg.Filter.CSVColumn, cfg.Filter.CSVHeader)
+		if err != nil {
+			return fmt.Errorf("failed to load values_csv: %w", err)
+		}
+		cfg.Filter.Values = append(cfg.Filter.Values, values...)
+	}
+	if err := validateConfig(v, &cfg); err != nil {
+		return err
+	}
+	if cfg.PrintConfig {
+		return printConfig(os.Stdout, &cfg)
+	}
+	app := application{config: cfg, logger: logger}
+	return app.serveProcessor()
+}
+
+// loadCSVValues reads the given zero-based column of every row of a CSV
+// file, skipping the first row if header is set and ignoring empty cells.
+// Files ending in .gz are decompressed.
+func loadCSVValues(path string, column int, header bool) ([]string, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for i, row := range rows {
+		if header && i == 0 {
+			continue
+		}
+		if column >= len(row) {
+			return nil, fmt.Errorf("row %d has no column %d", i+1, column)
+		}
+		if value := strings.TrimSpace(row[column]); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// joinList joins values into a comma-separated ini list, escaping commas
+// and backslashes inside the values so that they are read back unchanged.
+func joinList(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = strings.NewReplacer(`\`, `\\`, ",", `\,`).Replace(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// printConfig writes cfg as it was resolved from the config files in ini
+// format. The labels of output_scrub patterns are not kept, so they are
+// numbered instead.
+func printConfig(w io.Writer, cfg *config) error {
+	out := ini.Empty()
+	if err := out.ReflectFrom(cfg); err != nil {
+		return err
+	}
+	// values_csv is already merged into values, so reading it again
+	// would list its values twice.
+	filters := out.Section("filters")
+	for _, key := range []string{"values_csv", "values_csv_column", "values_csv_header"} {
+		filters.DeleteKey(key)
+	}
+	filters.Key("values").SetValue(joinList(cfg.Filter.Values))
+	filters.Key("block_values").SetValue(joinList(cfg.Filter.BlockValues))
+	scrub := out.Section("output_scrub")
+	for i, pattern := range cfg.Output.Scrub {
+		scrub.Key(fmt.Sprintf("pattern%d", i+1)).SetValue(pattern)
+	}
+	if len(cfg.Output.Compute) > 0 {
+		compute := out.Section("output_compute")
+		for _, f := range cfg.Output.Compute {
+			compute.Key(f.Name).SetValue(f.Expr)
+		}
+	}
+	if len(cfg.Filter.Aliases) > 0 {
+		aliases := out.Section("field_aliases")
+		for _, field := range slices.Sorted(maps.Keys(cfg.Filter.Aliases)) {
+			aliases.Key(field).SetValue(strings.Join(cfg.Filter.Aliases[field], ", "))
+		}
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
@@ -0,0 +1,109 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// quietHandler passes on the first limit warnings and errors with the
+// same level and message and counts the rest, so that a widespread
+// failure such as a full disk does not bury the log in identical lines.
+type quietHandler struct {
+	slog.Handler
+	limit int
+	state *quietState
+}
+
+// quietState is shared by a quietHandler and the handlers derived from it
+// with WithAttrs and WithGroup.
+type quietState struct {
+	mu     sync.Mutex
+	counts map[quietKey]int
+	order  []quietKey
+}
+
+type quietKey struct {
+	level slog.Level
+	msg   string
+}
+
+func newQuietHandler(h slog.Handler, limit int) *quietHandler {
+	return &quietHandler{
+		Handler: h,
+		limit:   limit,
+		state:   &quietState{counts: make(map[quietKey]int)},
+	}
+}
+
+func (h *quietHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	key := quietKey{level: r.Level, msg: r.Message}
+	h.state.mu.Lock()
+	n := h.state.counts[key] + 1
+	h.state.counts[key] = n
+	if n == 1 {
+		h.state.order = append(h.state.order, key)
+	}
+	h.state.mu.Unlock()
+
+	if n > h.limit {
+		return nil
+	}
+	if n == h.limit {
+		r.AddAttrs(slog.String("note", "further occurrences are suppressed"))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *quietHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &quietHandler{Handler: h.Handler.WithAttrs(attrs), limit: h.limit, state: h.state}
+}
+
+func (h *quietHandler) WithGroup(name string) slog.Handler {
+	return &quietHandler{Handler: h.Handler.WithGroup(name), limit: h.limit, state: h.state}
+}
+
+// flush logs one summary line for every message that was suppressed.
+func (h *quietHandler) flush(ctx context.Context) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	for _, key := range h.state.order {
+		n := h.state.counts[key]
+		if n <= h.limit {
+			continue
+		}
+		r := slog.NewRecord(time.Now(), key.level, fmt.Sprintf("%d occurrences of %q", n, key.msg), 0)
+		r.AddAttrs(slog.Int("suppressed", n-h.limit))
+		h.Handler.Handle(ctx, r)
+	}
+}
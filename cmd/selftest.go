@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/acquisitionist/r-proc/rproc"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	selfTestRecords = 1000
+	selfTestFile    = "RS_2023-01.zst"
+)
+
+// selfTestSubreddits are assigned to the synthetic records in turn, so the
+// self-test filter on golang matches every fourth record.
+var selfTestSubreddits = []string{"wallstreetbets", "golang", "AskScience", "LivestreamFail"}
+
+// selfTest writes a synthetic dump of fake submissions to a temporary
+// directory, processes it with a known filter and checks the number of
+// matches written. The directory is kept for inspection if the test fails.
+func selfTest(logger *slog.Logger) error {
+	dir, err := os.MkdirTemp("", "rproc-selftest-")
+	if err != nil {
+		return err
+	}
+	input := filepath.Join(dir, "input")
+	output := filepath.Join(dir, "output")
+	for _, d := range []string{input, output} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			return err
+		}
+	}
+
+	want, err := writeTestData(filepath.Join(input, selfTestFile))
+	if err != nil {
+		return fmt.Errorf("failed to write test data: %w", err)
+	}
+	logger.Info("wrote test data", "path", input, "records", selfTestRecords)
+
+	srv := &rproc.Processor{
+		Input:      input,
+		Output:     output,
+		Threads:    1,
+		Fields:     []string{"subreddit"},
+		Values:     []string{"golang"},
+		FileFilter: regexp.MustCompile(".*"),
+		Extensions: []string{".zst"},
+		MatchMode:  "exact",
+		TimeField:  "created_utc",
+		ErrorLog:   logger,
+	}
+	if err := srv.ProcessAndServe(); err != nil {
+		return fmt.Errorf("self-test failed, test data kept in %s: %w", dir, err)
+	}
+
+	got, err := countLines(filepath.Join(output, "RS_2023-01_golang.ndjson"))
+	if err != nil {
+		return fmt.Errorf("self-test failed, test data kept in %s: %w", dir, err)
+	}
+	if got != want {
+		return fmt.Errorf("self-test failed, test data kept in %s: got %d matches, want %d", dir, got, want)
+	}
+
+	logger.Info("self-test passed", "matches", got)
+	return os.RemoveAll(dir)
+}
+
+// writeTestData writes selfTestRecords fake submissions to path as zstd
+// compressed NDJSON and returns how many of them are in r/golang. Titles
+// mention golang in other subreddits too, which exact matching must skip.
+func writeTestData(path string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	enc, err := zstd.NewWriter(f)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(enc)
+	want := 0
+	for i := range selfTestRecords {
+		subreddit := selfTestSubreddits[i%len(selfTestSubreddits)]
+		if subreddit == "golang" {
+			want++
+		}
+		line, err := jsoniter.Marshal(map[string]any{
+			"id":           fmt.Sprintf("t3_%d", i),
+			"subreddit":    subreddit,
+			"author":       fmt.Sprintf("user%d", i%97),
+			"title":        fmt.Sprintf("post %d about golang", i),
+			"selftext":     "synthetic record written by -generate-test-data",
+			"score":        i % 500,
+			"num_comments": i % 50,
+			"created_utc":  1672531200 + int64(i)*60,
+		})
+		if err != nil {
+			return 0, err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := enc.Close(); err != nil {
+		return 0, err
+	}
+	return want, f.Close()
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n, scanner.Err()
+}
@@ -1,93 +1,314 @@
-/*
-MIT License
-
-Copyright (c) 2025 The R-Proc Contributors
-
-Permission is hereby granted, free of charge, to any person obtaining a copy
-of this software and associated documentation files (the "Software"), to deal
-in the Software without restriction, including without limitation the rights
-to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
-copies of the Software, and to permit persons to whom the Software is
-furnished to do so, subject to the following conditions:
-
-The above copyright notice and this permission notice shall be included in all
-copies or substantial portions of the Software.
-
-THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
-IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
-FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
-AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
-LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
-OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
-SOFTWARE.
-*/
-
-package main
-
-import (
-	"context"
-	"errors"
-	"log/slog"
-	"os"
-	"os/signal"
-	"regexp"
-	"syscall"
-	"time"
-)
-
-const (
-	defaultShutdownPeriod = 30 * time.Second
-)
-
-func (app *application) serveProcessor() error {
-	srv := &Processor{
-		Input:      app.config.Paths.Input,
-		Output:     app.config.Paths.Output,
-		Threads:    app.config.Threads,
-		Field:      app.config.Filter.Field,
-		Values:     app.config.Filter.Values,
-		FileFilter: regexp.MustCompile(app.config.Filter.FileFilter),
-		MatchMode:  app.config.Filter.MatchMode,
-
-		ErrorLog: slog.New(app.logger.Handler()),
-	}
-
-	err := app.serve(srv)
-	if err != nil {
-		return err
-	}
-
-	app.wg.Wait()
-	return nil
-}
-
-func (app *application) serve(srv *Processor) error {
-	shutdownErrorChan := make(chan error)
-
-	go func() {
-		quitChan := make(chan os.Signal, 1)
-		signal.Notify(quitChan, syscall.SIGINT, syscall.SIGTERM)
-		defer signal.Stop(quitChan)
-
-		<-quitChan
-
-		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownPeriod)
-		defer cancel()
-
-		shutdownErrorChan <- srv.Shutdown(ctx)
-	}()
-
-	app.logger.Info("starting processor", slog.Group("processor"))
-
-	err := srv.ProcessAndServe()
-	if !errors.Is(err, ErrProcessClosed) {
-		return err
-	}
-
-	if err := <-shutdownErrorChan; err != nil {
-		return err
-	}
-
-	app.logger.Info("stopped processor", slog.Group("processor"))
-	return nil
-}
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/acquisitionist/r-proc/rproc"
+)
+
+const (
+	defaultShutdownPeriod = 30 * time.Second
+)
+
+func (app *application) serveProcessor() error {
+	timeStart, err := rproc.ParseTime(app.config.Filter.TimeStart)
+	if err != nil {
+		return err
+	}
+	timeEnd, err := rproc.ParseTime(app.config.Filter.TimeEnd)
+	if err != nil {
+		return err
+	}
+	numberMin, err := parseBound(app.config.Filter.NumberMin)
+	if err != nil {
+		return err
+	}
+	numberMax, err := parseBound(app.config.Filter.NumberMax)
+	if err != nil {
+		return err
+	}
+
+	fileMode, err := parseFileMode(app.config.Output.FileMode)
+	if err != nil {
+		return err
+	}
+
+	handler := app.logger.Handler()
+	var quiet *quietHandler
+	if app.config.QuietErrors > 0 {
+		quiet = newQuietHandler(handler, app.config.QuietErrors)
+		handler = quiet
+	}
+
+	var scrub []*regexp.Regexp
+	for _, pattern := range app.config.Output.Scrub {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid output_scrub pattern %q: %w", pattern, err)
+		}
+		scrub = append(scrub, re)
+	}
+
+	var compute []rproc.ComputedField
+	for _, f := range app.config.Output.Compute {
+		field, err := rproc.ParseComputedField(f.Name, f.Expr)
+		if err != nil {
+			return fmt.Errorf("invalid output_compute %s: %w", f.Name, err)
+		}
+		compute = append(compute, field)
+	}
+
+	srv := &rproc.Processor{
+		Input:  app.config.Paths.Input,
+		Files:  app.config.Paths.Files,
+		Output: app.config.Paths.Output,
+
+		FollowSymlinks: app.config.Paths.Follow,
+		ZstdDict:       app.config.Paths.Dict,
+
+		Threads:    app.config.Threads,
+		FailFast:   app.config.FailFast,
+		Fields:     app.config.Filter.Fields,
+		Values:     app.config.Filter.Values,
+		FileFilter: regexp.MustCompile(app.config.Filter.FileFilter),
+		Extensions: app.config.Filter.Extensions,
+		MatchMode:  app.config.Filter.MatchMode,
+
+		ConcurrentDecodeSize: app.config.DecodeMB << 20,
+		SeekableChunkSize:    app.config.ChunkMB << 20,
+		ReadRateLimit:        app.config.ReadRate,
+
+		HTMLUnescape:  app.config.Filter.Unescape,
+		FieldAliases:  app.config.Filter.Aliases,
+		EmptyField:    app.config.Filter.EmptyField,
+		CombineValues: app.config.Filter.Combine,
+
+		RegexCaseInsensitive: app.config.Filter.RegexFold,
+
+		ExtractMaxValues: app.config.Filter.ExtractMax,
+		AggregateBucket:  app.config.Filter.Bucket,
+
+		BlockFields:    app.config.Filter.BlockFields,
+		BlockValues:    app.config.Filter.BlockValues,
+		BlockMatchMode: app.config.Filter.BlockMode,
+
+		DedupeField: app.config.Filter.DedupeField,
+		DedupeStore: app.config.Paths.Dedupe,
+		ResetDedupe: app.config.ResetDedupe,
+
+		DedupeOnClose: app.config.Filter.DedupeClose,
+
+		TimeField: app.config.Filter.TimeField,
+		TimeStart: timeStart,
+		TimeEnd:   timeEnd,
+
+		NumberField: app.config.Filter.NumberField,
+		NumberMin:   numberMin,
+		NumberMax:   numberMax,
+
+		HashSample:      app.config.Filter.HashSample,
+		HashSampleField: app.config.Filter.HashField,
+		HashSampleSalt:  app.config.Filter.HashSalt,
+
+		AnnotateSource:   app.config.Output.AnnotateSource,
+		CountOnly:        app.config.Output.CountOnly,
+		DebugSample:      app.config.Output.DebugSample,
+		Scrub:            scrub,
+		ScrubPlaceholder: app.config.Output.ScrubPlaceholder,
+		Compute:          compute,
+		SortField:        app.config.Output.SortField,
+		SortBufferLines:  app.config.Output.SortBufferLines,
+		OutputQueueDepth: app.config.Output.QueueDepth,
+		IOThreads:        app.config.Output.IOThreads,
+		InvalidUTF8:      app.config.Output.InvalidUTF8,
+		OutputLineEnding: app.config.Output.LineEnding,
+		OutputLayout:     app.config.Output.Layout,
+		OutputShards:     app.config.Output.Shards,
+		GroupBy:          app.config.Output.GroupBy,
+		TimeHistogram:    app.config.Output.TimeHistogram,
+		AtomicOutput:     app.config.Output.Atomic,
+		TruncateOutput:   !app.config.Output.Append,
+		ValidateOutput:   app.config.Output.Validate,
+		TrimOutput:       app.config.Output.Trim,
+		FlattenOutput:    app.config.Output.Flatten,
+		MaxOutputBytes:   app.config.Output.MaxBytes,
+		OutputFileMode:   fileMode,
+		ChmodOutput:      app.config.Output.Chmod,
+		OutputChecksums:  app.config.Output.Checksums,
+		StreamURL:        app.config.Output.StreamURL,
+		OrderedOutput:    app.config.Output.Ordered,
+
+		OutputCompression: app.config.Output.Compression,
+
+		RangeOffset: app.config.Offset,
+		RangeLength: app.config.Length,
+
+		OTelEndpoint: app.config.OTel,
+
+		CheckpointInterval: app.config.Output.Checkpoint,
+		ProgressInterval:   time.Duration(app.config.Output.Progress) * time.Second,
+		ProgressMode:       app.config.Output.ProgressMode,
+		RunReport:          app.config.Output.Report,
+
+		Watch:         app.config.Watch,
+		WatchInterval: app.config.WatchEvery,
+
+		Confirm:      !app.config.Yes,
+		ListFields:   app.config.ListFields,
+		ListNested:   app.config.ListNested,
+		FieldLengths: app.config.FieldLens,
+		BenchLines:   app.config.BenchLines,
+		ErrorLog:     slog.New(handler),
+	}
+
+	err = app.serve(srv)
+	if quiet != nil {
+		quiet.flush(context.Background())
+	}
+	if app.config.PostRun != "" && app.config.ListFields == 0 && app.config.FieldLens == 0 && app.config.BenchLines == 0 {
+		failed := err != nil || app.interrupted
+		if !failed || app.config.PostRunFail {
+			hookErr := app.runPostCommand(srv, err)
+			if err == nil {
+				err = hookErr
+			} else if hookErr != nil {
+				app.logger.Error(hookErr.Error())
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	app.wg.Wait()
+	if app.config.ProfileMem != "" {
+		return app.writeHeapProfile(app.config.ProfileMem)
+	}
+	return nil
+}
+
+// parseFileMode parses an octal permission string such as 0664. An empty
+// string yields zero, leaving the processor default in place.
+func parseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid output_file_mode %q: expected octal permissions such as 0644", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseBound parses an optional number_min or number_max. An empty string
+// yields nil, leaving that side of the range open.
+func parseBound(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number bound %q: %w", s, err)
+	}
+	return &n, nil
+}
+
+func (app *application) writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return err
+	}
+	app.logger.Info("wrote heap profile", "path", path)
+	return nil
+}
+
+func (app *application) serve(srv *rproc.Processor) error {
+	shutdownErrorChan := make(chan error)
+
+	go func() {
+		quitChan := make(chan os.Signal, 1)
+		signal.Notify(quitChan, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(quitChan)
+
+		var deadline <-chan time.Time
+		if app.config.MaxRuntime > 0 {
+			timer := time.NewTimer(app.config.MaxRuntime)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case <-quitChan:
+		case <-deadline:
+			app.logger.Warn("maximum runtime reached, stopping", "max_runtime", app.config.MaxRuntime)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownPeriod)
+		defer cancel()
+
+		shutdownErrorChan <- srv.Shutdown(ctx)
+	}()
+
+	stopPause := app.handlePause(srv)
+	defer stopPause()
+	stopThreads := app.handleThreads(srv)
+	defer stopThreads()
+	stopStats := app.handleStats(srv)
+	defer stopStats()
+
+	app.logger.Info("starting processor", slog.Group("processor"))
+
+	err := srv.ProcessAndServe()
+	if !errors.Is(err, rproc.ErrProcessClosed) {
+		return err
+	}
+
+	app.interrupted = true
+	if err := <-shutdownErrorChan; err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped processor", slog.Group("processor"))
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build !unix
+
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import "github.com/acquisitionist/r-proc/rproc"
+
+// handlePause does nothing on platforms without SIGUSR1.
+func (app *application) handlePause(srv *rproc.Processor) func() {
+	return func() {}
+}
+
+// handleStats does nothing on platforms without SIGHUP.
+func (app *application) handleStats(srv *rproc.Processor) func() {
+	return func() {}
+}
+
+// handleThreads does nothing on platforms without SIGUSR2.
+func (app *application) handleThreads(srv *rproc.Processor) func() {
+	return func() {}
+}
@@ -0,0 +1,143 @@
+//go:build unix
+
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/acquisitionist/r-proc/rproc"
+	"gopkg.in/ini.v1"
+)
+
+// handlePause toggles srv between paused and running on every SIGUSR1
+// until the returned function is called.
+func (app *application) handlePause(srv *rproc.Processor) func() {
+	pauseChan := make(chan os.Signal, 1)
+	signal.Notify(pauseChan, syscall.SIGUSR1)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-pauseChan:
+				if srv.Paused() {
+					srv.Resume()
+				} else {
+					srv.Pause()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(pauseChan)
+		close(done)
+	}
+}
+
+// handleStats logs a snapshot of the progress of srv on every SIGHUP until
+// the returned function is called.
+func (app *application) handleStats(srv *rproc.Processor) func() {
+	statsChan := make(chan os.Signal, 1)
+	signal.Notify(statsChan, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-statsChan:
+				srv.LogSnapshot()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(statsChan)
+		close(done)
+	}
+}
+
+// handleThreads re-reads threads from the config file on every SIGUSR2
+// and applies it to srv until the returned function is called.
+func (app *application) handleThreads(srv *rproc.Processor) func() {
+	threadsChan := make(chan os.Signal, 1)
+	signal.Notify(threadsChan, syscall.SIGUSR2)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-threadsChan:
+				threads, err := app.reloadThreads()
+				if err != nil {
+					app.logger.Warn("ignoring thread count change", "err", err)
+					continue
+				}
+				srv.SetThreads(threads)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(threadsChan)
+		close(done)
+	}
+}
+
+// reloadThreads reads the threads setting from the config files again.
+func (app *application) reloadThreads() (int, error) {
+	if slices.Contains(app.config.Paths.Config, "-") {
+		return 0, errors.New("config was read from stdin")
+	}
+	sources, err := configSources(app.config.Paths.Config)
+	if err != nil {
+		return 0, err
+	}
+	cfg, err := ini.Load(sources[0], sources[1:]...)
+	if err != nil {
+		return 0, err
+	}
+	threads, err := cfg.Section("").Key("threads").Int()
+	if err != nil {
+		return 0, err
+	}
+	if threads < 0 {
+		return 0, fmt.Errorf("invalid threads %d", threads)
+	}
+	return threads, nil
+}
@@ -0,0 +1,181 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// flagNames names the validated settings that come from the command line
+// rather than the config file.
+var flagNames = map[string]string{
+	"Config":      "-config",
+	"Files":       "file arguments",
+	"ListFields":  "-list-fields",
+	"FieldLens":   "-field-lengths",
+	"QuietErrors": "-quiet-errors",
+	"BenchLines":  "-bench",
+	"WatchEvery":  "-watch-interval",
+	"Offset":      "-offset",
+	"Length":      "-length",
+	"OTel":        "-otel-endpoint",
+}
+
+// newValidator returns the validator for config, which reports fields by
+// their setting names.
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(settingName)
+	return v
+}
+
+// validateConfig checks cfg. File arguments replace paths.input, so its
+// value is cleared rather than checked when any are given.
+func validateConfig(v *validator.Validate, cfg *config) error {
+	if len(cfg.Paths.Files) > 0 {
+		cfg.Paths.Input = ""
+	}
+	if slices.Contains(cfg.Paths.Config, "-") && cfg.Paths.Input == "-" {
+		return errors.New("config and input cannot both be read from stdin")
+	}
+	if err := v.Struct(cfg); err != nil {
+		return newConfigError(err)
+	}
+	return nil
+}
+
+// settingName returns the name under which a config field is set: its
+// ini key, or its flag for command-line settings.
+func settingName(f reflect.StructField) string {
+	if name, ok := flagNames[f.Name]; ok {
+		return name
+	}
+	if name := f.Tag.Get("ini"); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+// configError lists the settings of a config that failed validation,
+// each as a readable sentence such as "filters.match_mode must be one of
+// exact, partial, regex".
+type configError []string
+
+func (e configError) Error() string {
+	return "invalid config: " + strings.Join(e, "; ")
+}
+
+// newConfigError translates the errors of validating cfg into a
+// configError. Other errors are returned unchanged.
+func newConfigError(err error) error {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	var e configError
+	for _, fe := range fieldErrs {
+		e = append(e, describeFieldError(fe))
+	}
+	return e
+}
+
+func describeFieldError(fe validator.FieldError) string {
+	name := strings.TrimPrefix(fe.Namespace(), "config.")
+	if field, _, _ := strings.Cut(fe.StructField(), "["); flagNames[field] != "" {
+		name = fe.Field()
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return name + " is required"
+	case "required_with":
+		return fmt.Sprintf("%s is required with %s", name, siblingName(fe, fe.Param()))
+	case "required_without":
+		return fmt.Sprintf("%s is required without %s", name, siblingName(fe, fe.Param()))
+	case "required_unless":
+		var conds []string
+		params := strings.Fields(fe.Param())
+		for i := 0; i+1 < len(params); i += 2 {
+			conds = append(conds, siblingName(fe, params[i])+" is "+params[i+1])
+		}
+		return fmt.Sprintf("%s is required unless %s", name, strings.Join(conds, " or "))
+	}
+
+	// Alternatives such as file|http_url keep their parameters in the tag.
+	var wants []string
+	for _, alt := range strings.Split(fe.Tag(), "|") {
+		tag, param, ok := strings.Cut(alt, "=")
+		if !ok {
+			param = fe.Param()
+		}
+		wants = append(wants, describeTag(tag, param))
+	}
+	return fmt.Sprintf("%s must be %s, got %q", name, strings.Join(wants, " or "), fmt.Sprint(fe.Value()))
+}
+
+// describeTag describes what a validation tag accepts, to follow "must be".
+func describeTag(tag, param string) string {
+	switch tag {
+	case "oneof":
+		return "one of " + strings.Join(strings.Fields(param), ", ")
+	case "gte":
+		return param + " or more"
+	case "eq":
+		return param
+	case "file":
+		return "an existing file"
+	case "dir":
+		return "an existing directory"
+	case "http_url":
+		return "an http(s) URL"
+	default:
+		return "valid for " + tag
+	}
+}
+
+// siblingName returns the setting name of field, a field of the same
+// struct as the one fe reports on.
+func siblingName(fe validator.FieldError, field string) string {
+	t := reflect.TypeOf(config{})
+	parts := strings.Split(fe.StructNamespace(), ".")
+	for _, part := range parts[1 : len(parts)-1] {
+		f, ok := t.FieldByName(part)
+		if !ok {
+			return field
+		}
+		t = f.Type
+	}
+	f, ok := t.FieldByName(field)
+	if !ok {
+		return field
+	}
+	return settingName(f)
+}
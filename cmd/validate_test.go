@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validConfig returns a config that passes validation, with the defaults
+// run sets before the config files are read.
+func validConfig(t *testing.T) config {
+	t.Helper()
+	var cfg config
+	cfg.Paths.Config = []string{"-"}
+	cfg.Paths.Input = t.TempDir()
+	cfg.Paths.Output = t.TempDir()
+	cfg.Filter.Fields = []string{"subreddit"}
+	cfg.Filter.Values = []string{"golang"}
+	cfg.Filter.FileFilter = ".*"
+	cfg.Filter.Extensions = []string{".zst"}
+	cfg.Filter.MatchMode = "exact"
+	cfg.Filter.TimeField = "created_utc"
+	cfg.Filter.HashField = "id"
+	cfg.Filter.NumberField = "score"
+	cfg.Filter.BlockMode = "exact"
+	cfg.Filter.EmptyField = "skip"
+	return cfg
+}
+
+// TestMatchModeOneof checks that unknown match modes are rejected by their
+// oneof rule, and that an empty block_match_mode is not accepted as an
+// empty first option.
+func TestMatchModeOneof(t *testing.T) {
+	v := newValidator()
+	if err := v.Struct(validConfig(t)); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		set   func(*config)
+		field string
+	}{
+		{"match_mode", func(c *config) { c.Filter.MatchMode = "bogus" }, "MatchMode"},
+		{"block_match_mode", func(c *config) { c.Filter.BlockMode = "bogus" }, "BlockMode"},
+		{"block_match_mode", func(c *config) { c.Filter.BlockMode = "" }, "BlockMode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.set(&cfg)
+			err := v.Struct(cfg)
+			var fieldErrs validator.ValidationErrors
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("invalid %s accepted, err = %v", tt.name, err)
+			}
+			found := false
+			for _, fe := range fieldErrs {
+				if fe.StructField() == tt.field && fe.Tag() == "oneof" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("got %v, want a oneof error on %s", err, tt.field)
+			}
+			if msg := newConfigError(err).Error(); !strings.Contains(msg, tt.name+" must be one of exact, partial, regex") {
+				t.Errorf("message %q does not list the match modes", msg)
+			}
+		})
+	}
+}
+
+// TestMatchModeRequired checks that an empty match mode is reported as
+// missing rather than as an unknown mode.
+func TestMatchModeRequired(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Filter.MatchMode = ""
+	err := newValidator().Struct(cfg)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("empty match_mode accepted, err = %v", err)
+	}
+	if len(fieldErrs) != 1 || fieldErrs[0].StructField() != "MatchMode" || fieldErrs[0].Tag() != "required" {
+		t.Errorf("got %v, want a single required error on MatchMode", err)
+	}
+	if msg := newConfigError(err).Error(); !strings.Contains(msg, "filters.match_mode is required") {
+		t.Errorf("message %q does not say match_mode is required", msg)
+	}
+}
+
+// TestFilesReplaceInput checks that file arguments bypass the checks on
+// paths.input, which they replace.
+func TestFilesReplaceInput(t *testing.T) {
+	v := newValidator()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "RC_2024-01.zst")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := validConfig(t)
+	cfg.Paths.Input = filepath.Join(dir, "missing")
+	cfg.Paths.Files = []string{file}
+	if err := validateConfig(v, &cfg); err != nil {
+		t.Fatalf("config with file arguments rejected: %v", err)
+	}
+	if cfg.Paths.Input != "" {
+		t.Errorf("input = %q, want it cleared", cfg.Paths.Input)
+	}
+
+	cfg = validConfig(t)
+	cfg.Paths.Input = filepath.Join(dir, "missing")
+	if err := validateConfig(v, &cfg); err == nil {
+		t.Error("missing input accepted without file arguments")
+	}
+}
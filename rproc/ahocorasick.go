@@ -0,0 +1,126 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ahoCorasick finds which of a set of literal patterns occur in a text in
+// a single pass over it, however many patterns there are.
+type ahoCorasick struct {
+	root  [256]int32 // transitions from the root, which never fail
+	nodes []acNode
+}
+
+type acNode struct {
+	edges []acEdge // sorted by byte
+	fail  int32
+	out   int32 // lowest index of a pattern ending here or at a suffix, or -1
+}
+
+type acEdge struct {
+	b    byte
+	next int32
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	ac := &ahoCorasick{nodes: []acNode{{out: -1}}}
+	for i, pattern := range patterns {
+		var n int32
+		for j := 0; j < len(pattern); j++ {
+			child, ok := ac.child(n, pattern[j])
+			if !ok {
+				child = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{out: -1})
+				edges := ac.nodes[n].edges
+				pos, _ := slices.BinarySearchFunc(edges, pattern[j], func(e acEdge, b byte) int { return cmp.Compare(e.b, b) })
+				ac.nodes[n].edges = slices.Insert(edges, pos, acEdge{b: pattern[j], next: child})
+			}
+			n = child
+		}
+		ac.nodes[n].out = lowestOut(ac.nodes[n].out, int32(i))
+	}
+
+	for b := range 256 {
+		ac.root[b], _ = ac.child(0, byte(b))
+	}
+
+	// Breadth-first, so a node's fail link always points to a node whose
+	// own links are already complete.
+	var queue []int32
+	for _, e := range ac.nodes[0].edges {
+		queue = append(queue, e.next)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range ac.nodes[n].edges {
+			fail := ac.step(ac.nodes[n].fail, e.b)
+			ac.nodes[e.next].fail = fail
+			ac.nodes[e.next].out = lowestOut(ac.nodes[e.next].out, ac.nodes[fail].out)
+			queue = append(queue, e.next)
+		}
+	}
+	return ac
+}
+
+func lowestOut(a, b int32) int32 {
+	if a < 0 || (b >= 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func (ac *ahoCorasick) child(n int32, b byte) (int32, bool) {
+	edges := ac.nodes[n].edges
+	i, ok := slices.BinarySearchFunc(edges, b, func(e acEdge, b byte) int { return cmp.Compare(e.b, b) })
+	if !ok {
+		return 0, false
+	}
+	return edges[i].next, true
+}
+
+func (ac *ahoCorasick) step(n int32, b byte) int32 {
+	for n != 0 {
+		if child, ok := ac.child(n, b); ok {
+			return child
+		}
+		n = ac.nodes[n].fail
+	}
+	return ac.root[b]
+}
+
+// first returns the lowest index of the patterns that occur in text.
+func (ac *ahoCorasick) first(text string) (int, bool) {
+	best := ac.nodes[0].out
+	var n int32
+	for i := 0; i < len(text) && best != 0; i++ {
+		n = ac.step(n, text[i])
+		best = lowestOut(best, ac.nodes[n].out)
+	}
+	return int(best), best >= 0
+}
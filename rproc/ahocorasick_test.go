@@ -0,0 +1,143 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		text     string
+		want     int // lowest index of a pattern in text, or -1
+	}{
+		// Overlapping patterns: all of she, he and hers end inside
+		// "ushers", and the lowest index wins.
+		{[]string{"hers", "his", "she", "he"}, "ushers", 0},
+		{[]string{"his", "she", "he"}, "ushers", 1},
+		{[]string{"his", "he"}, "ushers", 1},
+		{[]string{"his", "hers"}, "ushe", -1},
+		// A pattern that is a suffix of a longer one is found through
+		// the fail link of the longer one's node.
+		{[]string{"abcd", "bc"}, "abce", 1},
+		{[]string{"abcd", "c"}, "abce", 1},
+		// After a mismatch the search continues from the longest suffix
+		// that is a prefix of some pattern.
+		{[]string{"abcx", "bcd"}, "abcd", 1},
+		{[]string{"aab"}, "aaab", 0},
+		{[]string{"abab"}, "abaabab", 0},
+		{[]string{"abab"}, "ababa", 0},
+		{[]string{"abab"}, "abaab", -1},
+		// The lowest index wins even when it occurs later in the text.
+		{[]string{"zzz", "a"}, "a zzz", 0},
+		{[]string{"a", "a"}, "xa", 0},
+		// An empty pattern occurs in every text.
+		{[]string{"q", ""}, "abc", 1},
+		{[]string{""}, "", 0},
+		{[]string{"a"}, "", -1},
+		{nil, "abc", -1},
+		// Bytes beyond ASCII.
+		{[]string{"ü", "é"}, "café", 1},
+		{[]string{"\xff\x00"}, "a\xff\x00b", 0},
+	}
+	for _, tt := range tests {
+		i, ok := newAhoCorasick(tt.patterns).first(tt.text)
+		if !ok {
+			i = -1
+		}
+		if i != tt.want {
+			t.Errorf("patterns %q in %q: got %d, want %d", tt.patterns, tt.text, i, tt.want)
+		}
+	}
+}
+
+// TestAhoCorasickMatchesContains compares the automaton with a plain
+// strings.Contains loop on random patterns over a small alphabet, which
+// produces many overlaps and shared prefixes.
+func TestAhoCorasickMatchesContains(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	word := func(max int) string {
+		b := make([]byte, rng.IntN(max)+1)
+		for i := range b {
+			b[i] = "abc"[rng.IntN(3)]
+		}
+		return string(b)
+	}
+	for range 500 {
+		patterns := make([]string, rng.IntN(20)+1)
+		for i := range patterns {
+			patterns[i] = word(5)
+		}
+		ac := newAhoCorasick(patterns)
+		for range 20 {
+			text := word(30)
+			want := -1
+			for i, p := range patterns {
+				if strings.Contains(text, p) {
+					want = i
+					break
+				}
+			}
+			got, ok := ac.first(text)
+			if !ok {
+				got = -1
+			}
+			if got != want {
+				t.Fatalf("patterns %q in %q: got %d, want %d", patterns, text, got, want)
+			}
+		}
+	}
+}
+
+// TestAutomatonMatcherAttribution checks that partial mode reports the
+// same value with and without the automaton.
+func TestAutomatonMatcherAttribution(t *testing.T) {
+	values := []string{"Go", "golang", "Rust", "rustacean", "ai", "AI art"}
+	for i := len(values); i < ahoCorasickMinValues; i++ {
+		values = append(values, fmt.Sprintf("filler%d", i))
+	}
+	vf, err := newValueFilter([]string{"title"}, values, "partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vf.matcher.(automatonMatcher); !ok {
+		t.Fatalf("partial mode with %d values uses %T, want automatonMatcher", len(values), vf.matcher)
+	}
+	loop := partialMatcher(values)
+	for _, field := range []string{
+		"", "nothing here", "GOLANG tips", "a rustacean writes go", "Rustacean",
+		"FAIR ARTists", "filler7 and filler15", "filler1", "fillerX",
+	} {
+		got, gotOK := vf.matcher.Match(field, nil)
+		want, wantOK := loop.Match(field, nil)
+		if got != want || gotOK != wantOK {
+			t.Errorf("%q: automaton matched %q, %v; loop matched %q, %v", field, got, gotOK, want, wantOK)
+		}
+	}
+}
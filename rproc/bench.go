@@ -0,0 +1,110 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// benchModes are the match modes compared by benchmark, in addition to
+// the configured one. The type and jq modes take different kinds of
+// values and are only measured when configured.
+var benchModes = []string{"exact", "partial", "word", "regex"}
+
+// benchDuration is the minimum time each mode is measured for.
+const benchDuration = 500 * time.Millisecond
+
+// benchmark samples the first BenchLines lines of each file and writes the
+// matching throughput of every match mode over the sample to w, without
+// writing any output.
+func (p *Processor) benchmark(files []string, w io.Writer) error {
+	var sample [][]byte
+	var sampleFiles []string
+	for _, file := range files {
+		if p.shuttingDown() {
+			return ErrProcessClosed
+		}
+		_, err := p.readSample(file, p.BenchLines, func(line []byte) bool {
+			if len(line) == 0 {
+				return false
+			}
+			sample = append(sample, slices.Clone(line))
+			sampleFiles = append(sampleFiles, file)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("sampling %s: %w", file, err)
+		}
+	}
+	if len(sample) == 0 {
+		return fmt.Errorf("no lines to benchmark")
+	}
+
+	modes := benchModes
+	if !slices.Contains(modes, p.MatchMode) {
+		modes = append(slices.Clone(modes), p.MatchMode)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "MODE\tLINES/SEC\tMATCHES\n")
+	for _, mode := range modes {
+		vf, err := newValueFilter(p.Fields, p.Values, mode)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t-\t%s\n", mode, err)
+			continue
+		}
+		vf.unescape = p.HTMLUnescape
+		vf.aliases = p.FieldAliases
+		if p.CombineValues {
+			if err := vf.combine(); err != nil {
+				fmt.Fprintf(tw, "%s\t-\t%s\n", mode, err)
+				continue
+			}
+		}
+
+		var matches int
+		var lines int64
+		start := time.Now()
+		for pass := 0; pass == 0 || time.Since(start) < benchDuration; pass++ {
+			for i, line := range sample {
+				if _, ok := vf.match(sampleFiles[i], line); ok && pass == 0 {
+					matches++
+				}
+			}
+			lines += int64(len(sample))
+		}
+		elapsed := time.Since(start)
+		fmt.Fprintf(tw, "%s\t%.0f\t%d\n", mode, float64(lines)/elapsed.Seconds(), matches)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d lines sampled from %d files\n", len(sample), len(files))
+	return err
+}
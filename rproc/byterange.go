@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// alignToRange reads reader, which is at decompressed offset from, up to
+// offset and past the rest of the line that offset falls into, unless a
+// line starts exactly there. It returns a reader at the first line that
+// starts at or after offset, and that line's offset. A range beyond the
+// end of the input yields a reader at its end.
+func alignToRange(reader io.Reader, from, offset int64) (io.Reader, int64, error) {
+	br := bufio.NewReaderSize(reader, 64<<10)
+	// The byte before the range tells whether a line starts at it.
+	pos := offset - 1
+	if _, err := io.CopyN(io.Discard, br, pos-from); err != nil {
+		if errors.Is(err, io.EOF) {
+			return br, pos, nil
+		}
+		return nil, 0, err
+	}
+	for {
+		chunk, err := br.ReadSlice('\n')
+		pos += int64(len(chunk))
+		switch {
+		case err == nil:
+			return br, pos, nil
+		case errors.Is(err, io.EOF):
+			return br, pos, nil
+		case !errors.Is(err, bufio.ErrBufferFull):
+			return nil, 0, err
+		}
+	}
+}
+
+// checkLineDelimited returns an error if one of files is a single JSON
+// array, whose records have no byte offsets a range could start at. Files
+// that cannot be read are left for their worker to report.
+func (p *Processor) checkLineDelimited(files []string) error {
+	for _, file := range files {
+		if isArray, err := p.isArrayInput(file); err == nil && isArray {
+			return fmt.Errorf("%s: a byte range needs line-delimited input, not a JSON array", file)
+		}
+	}
+	return nil
+}
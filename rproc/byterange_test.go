@@ -0,0 +1,95 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// rangeProcessor returns a processor reading the byte range of data given
+// by offset and length, which adds the ids it matches to seen.
+func rangeProcessor(t *testing.T, data []byte, offset, length int64, seen map[string]int) *Processor {
+	return &Processor{
+		Output:      t.TempDir(),
+		Files:       []string{"RC_range.ndjson"},
+		Threads:     1,
+		RangeOffset: offset,
+		RangeLength: length,
+		Fields:      []string{"subreddit"},
+		Values:      []string{"golang"},
+		FileFilter:  regexp.MustCompile(".*"),
+		Extensions:  []string{".ndjson"},
+		MatchMode:   "exact",
+		TimeField:   "created_utc",
+		OpenInput:   MemoryInput(map[string][]byte{"RC_range.ndjson": data}),
+		OnMatch: func(_, _ string, line []byte) {
+			seen[jsoniter.Get(line, "id").ToString()]++
+		},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// TestByteRangeCRLF checks that two adjacent ranges of an input with CRLF
+// line endings cover every record exactly once wherever they are split.
+func TestByteRangeCRLF(t *testing.T) {
+	const records = 20
+	var data bytes.Buffer
+	for i := range records {
+		fmt.Fprintf(&data, `{"id":"t3_%d","subreddit":"golang"}`+"\r\n", i)
+	}
+
+	for split := int64(1); split < int64(data.Len()); split += 7 {
+		seen := make(map[string]int)
+		if err := rangeProcessor(t, data.Bytes(), 0, split, seen).ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rangeProcessor(t, data.Bytes(), split, 0, seen).ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+		for i := range records {
+			if id := fmt.Sprintf("t3_%d", i); seen[id] != 1 {
+				t.Errorf("split at %d: record %s matched %d times, want 1", split, id, seen[id])
+			}
+		}
+	}
+}
+
+// TestByteRangeJSONArray checks that a byte range over a JSON array input
+// is rejected before processing starts.
+func TestByteRangeJSONArray(t *testing.T) {
+	data := []byte(`[{"id":"t3_0","subreddit":"golang"},{"id":"t3_1","subreddit":"golang"}]`)
+	err := rangeProcessor(t, data, 0, 10, make(map[string]int)).ProcessAndServe()
+	if err == nil || !strings.Contains(err.Error(), "not a JSON array") {
+		t.Errorf("got %v, want an error about the JSON array", err)
+	}
+}
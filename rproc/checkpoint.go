@@ -0,0 +1,80 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// checkpoint records how far into an input file processing got. Line and
+// Offset count decompressed lines and bytes, since zstd streams cannot be
+// seeked and must be decompressed and discarded up to that point.
+type checkpoint struct {
+	Line     int64 `json:"line"`
+	Offset   int64 `json:"offset"`
+	Complete bool  `json:"complete"`
+}
+
+func (p *Processor) checkpointPath(file string) string {
+	return filepath.Join(p.Output, inputName(file)+".checkpoint")
+}
+
+// loadCheckpoint returns the saved checkpoint for file, or the zero
+// checkpoint if the file has not been started.
+func (p *Processor) loadCheckpoint(file string) (checkpoint, error) {
+	var cp checkpoint
+	b, err := os.ReadFile(p.checkpointPath(file))
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	err = jsoniter.Unmarshal(b, &cp)
+	return cp, err
+}
+
+// saveCheckpoint replaces the checkpoint for file via a rename so that a
+// crash never leaves a truncated checkpoint behind.
+func (p *Processor) saveCheckpoint(file string, cp checkpoint) {
+	path := p.checkpointPath(file)
+	b, err := jsoniter.Marshal(cp)
+	if err == nil {
+		err = os.WriteFile(path+".tmp", b, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		p.ErrorLog.Warn("failed to save checkpoint",
+			"path", path,
+			"err", err,
+		)
+	}
+}
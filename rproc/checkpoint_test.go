@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	p := &Processor{Output: t.TempDir(), ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cp, err := p.loadCheckpoint("RC_cp.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp != (checkpoint{}) {
+		t.Errorf("checkpoint of an unstarted file = %+v, want the zero checkpoint", cp)
+	}
+
+	want := checkpoint{Line: 42, Offset: 4096}
+	p.saveCheckpoint("RC_cp.zst", want)
+	if cp, err = p.loadCheckpoint("RC_cp.zst"); err != nil || cp != want {
+		t.Errorf("loadCheckpoint = %+v, %v, want %+v", cp, err, want)
+	}
+}
+
+// TestCheckpointResume checks that a run resumes a zstd file after the
+// line of its checkpoint, marks the file complete, and that a later run
+// skips the completed file.
+func TestCheckpointResume(t *testing.T) {
+	const records = 100
+	data := zstdFrame(t, 0, records-1)
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	p := func(out string) *Processor {
+		return &Processor{
+			Output:             out,
+			Files:              []string{"RC_cp.zst"},
+			Threads:            1,
+			Fields:             []string{"subreddit"},
+			Values:             []string{"golang"},
+			FileFilter:         regexp.MustCompile(".*"),
+			Extensions:         []string{".zst"},
+			MatchMode:          "exact",
+			TimeField:          "created_utc",
+			CheckpointInterval: 10,
+			OpenInput:          MemoryInput(map[string][]byte{"RC_cp.zst": data}),
+			OnMatch: func(_, _ string, line []byte) {
+				mu.Lock()
+				seen = append(seen, jsoniter.Get(line, "id").ToString())
+				mu.Unlock()
+			},
+			ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+	}
+
+	out := t.TempDir()
+	first := p(out)
+	first.saveCheckpoint("RC_cp.zst", checkpoint{Line: 60})
+	if err := first.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != records-60 || seen[0] != "t3_60" || seen[len(seen)-1] != fmt.Sprintf("t3_%d", records-1) {
+		t.Errorf("resumed run matched %d records from %v, want t3_60 to t3_%d", len(seen), seen[:min(len(seen), 1)], records-1)
+	}
+	cp, err := first.loadCheckpoint("RC_cp.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for i := range records {
+		size += int64(len(fmt.Sprintf(`{"id":"t3_%d","subreddit":"golang"}`+"\n", i)))
+	}
+	if want := (checkpoint{Line: records, Offset: size, Complete: true}); cp != want {
+		t.Errorf("checkpoint after the run = %+v, want %+v", cp, want)
+	}
+
+	seen = nil
+	if err := p(out).ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 0 {
+		t.Errorf("completed file matched %d records again", len(seen))
+	}
+}
@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checksums keeps a running SHA-256 of every output file written during a
+// run, so that a .sha256 sidecar can be written when processing ends
+// without reading the output again.
+type checksums struct {
+	mu     sync.Mutex
+	hashes map[string]*fileHash
+}
+
+// fileHash is the running hash of one output file. Its lock is held
+// across each write and the matching hash update, so the hash follows the
+// order of the data in the file.
+type fileHash struct {
+	mu sync.Mutex
+	h  hash.Hash
+}
+
+func newChecksums() *checksums {
+	return &checksums{hashes: make(map[string]*fileHash)}
+}
+
+// get returns the running hash of path. The first time a path is seen, the
+// hash is seeded with what the file already holds, since output is
+// appended to existing files.
+func (c *checksums) get(path string) (*fileHash, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fh, ok := c.hashes[path]; ok {
+		return fh, nil
+	}
+
+	fh := &fileHash{h: sha256.New()}
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		_, err := io.Copy(fh.h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.hashes[path] = fh
+	return fh, nil
+}
+
+// rename moves the hash of a staged file to its final name.
+func (c *checksums) rename(from, to string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fh, ok := c.hashes[from]; ok {
+		delete(c.hashes, from)
+		c.hashes[to] = fh
+	}
+}
+
+// discard forgets the hash of a file that was removed.
+func (c *checksums) discard(path string) {
+	c.mu.Lock()
+	delete(c.hashes, path)
+	c.mu.Unlock()
+}
+
+// writeChecksums writes a <file>.sha256 sidecar in sha256sum format for
+// every output file written during the run.
+func (p *Processor) writeChecksums() {
+	c := p.checksums
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, fh := range c.hashes {
+		line := fmt.Sprintf("%x  %s\n", fh.h.Sum(nil), filepath.Base(path))
+		if err := os.WriteFile(path+".sha256", []byte(line), 0644); err != nil {
+			p.ErrorLog.Warn("failed to write checksum", "path", path, "err", err)
+		}
+	}
+}
+
+// hashedFile updates the running hash of an output file with everything
+// written to it.
+type hashedFile struct {
+	*os.File
+	fh *fileHash
+}
+
+func (f *hashedFile) Write(b []byte) (int, error) {
+	f.fh.mu.Lock()
+	defer f.fh.mu.Unlock()
+	n, err := writeAll(f.File, b)
+	f.fh.h.Write(b[:n])
+	return n, err
+}
+
+func (f *hashedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
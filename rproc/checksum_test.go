@@ -0,0 +1,85 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestOutputChecksums checks that the .sha256 sidecar matches the output
+// file, also after a second run appended to it.
+func TestOutputChecksums(t *testing.T) {
+	out := t.TempDir()
+	run := func(id string) {
+		t.Helper()
+		p := &Processor{
+			Output:          out,
+			Files:           []string{"RC_sum.ndjson"},
+			Threads:         2,
+			Fields:          []string{"subreddit"},
+			Values:          []string{"golang"},
+			FileFilter:      regexp.MustCompile(".*"),
+			Extensions:      []string{".ndjson"},
+			MatchMode:       "exact",
+			TimeField:       "created_utc",
+			OutputChecksums: true,
+			OpenInput: MemoryInput(map[string][]byte{
+				"RC_sum.ndjson": []byte(`{"id":"` + id + `","subreddit":"golang"}` + "\n" +
+					`{"id":"` + id + `x","subreddit":"rust"}` + "\n"),
+			}),
+			ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+		if err := p.ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(out, "RC_sum_golang.ndjson")
+	for i, id := range []string{"t1_a", "t1_b"} {
+		run(id)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sidecar, err := os.ReadFile(path + ".sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("%x  RC_sum_golang.ndjson\n", sha256.Sum256(data))
+		if string(sidecar) != want {
+			t.Errorf("run %d: checksum file %q, want %q", i+1, sidecar, want)
+		}
+	}
+	if ids := outputIDs(t, out); len(ids) != 2 {
+		t.Errorf("output holds %v, want the matches of both runs", ids)
+	}
+}
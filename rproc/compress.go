@@ -0,0 +1,263 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// outputFrameSize is how many bytes of lines are collected for an output
+// file before they are compressed into a frame and appended to it. Larger
+// frames compress better but are held in memory for every output file.
+const outputFrameSize = 256 << 10
+
+// incompressibleRatio is the compressed share of a frame above which the
+// lines of an output file are taken not to compress, so that its later
+// frames use the fastest level instead of spending CPU for nothing.
+const incompressibleRatio = 0.9
+
+// compressedOutputs collects the lines written to each output file with
+// OutputCompression and appends them as independent zstd frames, which
+// together read as a single zstd stream. Files are still opened for every
+// write, so staging, checksums and appending work as for plain output.
+type compressedOutputs struct {
+	enc   *frameEncoder
+	files sync.Map // output path -> *compressedOutput
+	in    atomic.Int64
+	out   atomic.Int64
+}
+
+// compressedOutput holds the lines of one output file not yet written.
+type compressedOutput struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines int64
+	poor  bool // the last frame hardly compressed
+}
+
+// compressing reports whether OutputCompression is enabled.
+func (p *Processor) compressing() bool {
+	return p.OutputCompression != "" && p.OutputCompression != "none"
+}
+
+func newCompressedOutputs(auto bool) (*compressedOutputs, error) {
+	enc, err := newFrameEncoder(auto)
+	if err != nil {
+		return nil, err
+	}
+	return &compressedOutputs{enc: enc}, nil
+}
+
+// frameEncoder compresses output frames at the default level, or with
+// auto at a level picked from the CPU time left over by the run.
+type frameEncoder struct {
+	auto     bool
+	encoders map[zstd.EncoderLevel]*zstd.Encoder
+
+	mu      sync.Mutex
+	level   zstd.EncoderLevel
+	sampled time.Time
+	cpu     time.Duration
+}
+
+func newFrameEncoder(auto bool) (*frameEncoder, error) {
+	levels := []zstd.EncoderLevel{zstd.SpeedDefault}
+	if auto {
+		levels = append(levels, zstd.SpeedFastest, zstd.SpeedBetterCompression)
+	}
+	e := &frameEncoder{
+		auto:     auto,
+		encoders: make(map[zstd.EncoderLevel]*zstd.Encoder),
+		level:    zstd.SpeedDefault,
+		sampled:  time.Now(),
+	}
+	for _, level := range levels {
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+		if err != nil {
+			return nil, err
+		}
+		e.encoders[level] = enc
+	}
+	if user, system, ok := cpuTime(); ok {
+		e.cpu = user + system
+	}
+	return e, nil
+}
+
+// encode compresses data as one frame. poor picks the fastest level in
+// auto mode, for output files whose lines hardly compress.
+func (e *frameEncoder) encode(data []byte, poor bool) []byte {
+	level := zstd.SpeedDefault
+	if e.auto {
+		level = zstd.SpeedFastest
+		if !poor {
+			level = e.headroomLevel()
+		}
+	}
+	return e.encoders[level].EncodeAll(data, make([]byte, 0, len(data)/2))
+}
+
+// headroomLevel returns the level for the next frame in auto mode: better
+// compression while at least half of the CPUs are idle, the default while
+// at least one is, and the fastest level once the process keeps them all
+// busy, so that compressing output does not slow down decompressing input.
+// The CPU time of the process is sampled at most once a second; where the
+// platform does not report it, the default level is kept.
+func (e *frameEncoder) headroomLevel() zstd.EncoderLevel {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if now.Sub(e.sampled) < time.Second {
+		return e.level
+	}
+	user, system, ok := cpuTime()
+	if !ok {
+		return e.level
+	}
+	busy := float64(user+system-e.cpu) / float64(now.Sub(e.sampled))
+	e.sampled, e.cpu = now, user+system
+
+	cpus := float64(runtime.NumCPU())
+	switch idle := cpus - busy; {
+	case idle >= cpus/2:
+		e.level = zstd.SpeedBetterCompression
+	case idle >= 1:
+		e.level = zstd.SpeedDefault
+	default:
+		e.level = zstd.SpeedFastest
+	}
+	return e.level
+}
+
+// writeCompressed adds s to the lines collected for path and appends them
+// as a frame once they fill one. If that fails, the lines are dropped and
+// their number is returned with the error.
+func (p *Processor) writeCompressed(path, s string) (int64, error) {
+	v, _ := p.compressed.files.LoadOrStore(path, new(compressedOutput))
+	o := v.(*compressedOutput)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.WriteString(s)
+	o.lines += int64(strings.Count(s, "\n"))
+	if o.buf.Len() < outputFrameSize {
+		return 0, nil
+	}
+	return p.flushFrame(path, o)
+}
+
+// flushFrame compresses the lines collected in o and appends them to path
+// as one frame. o.mu must be held.
+func (p *Processor) flushFrame(path string, o *compressedOutput) (int64, error) {
+	data := o.buf.Bytes()
+	lines := o.lines
+	defer func() {
+		o.buf.Reset()
+		o.lines = 0
+	}()
+
+	frame := p.compressed.enc.encode(data, o.poor)
+	o.poor = float64(len(frame)) > incompressibleRatio*float64(len(data))
+	out, err := p.openOutput(path)
+	if err != nil {
+		return lines, err
+	}
+	_, err = out.Write(frame)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return lines, err
+	}
+	p.compressed.in.Add(int64(len(data)))
+	p.compressed.out.Add(int64(len(frame)))
+	return 0, nil
+}
+
+// frameFailed reports the lines of a frame that could not be written.
+func (p *Processor) frameFailed(path string, lines int64, err error) {
+	if p.stopIfDiskFull(err) {
+		p.diskDropped.Add(lines - 1)
+		return
+	}
+	p.ErrorLog.Warn("failed to write to output file",
+		"path", path,
+		"lines", lines,
+		"err", err,
+	)
+}
+
+// flushCompressed appends the lines still collected for every output file
+// and logs how well the output compressed.
+func (p *Processor) flushCompressed() {
+	p.compressed.files.Range(func(key, v any) bool {
+		path, o := key.(string), v.(*compressedOutput)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.buf.Len() == 0 {
+			return true
+		}
+		if lines, err := p.flushFrame(path, o); err != nil {
+			p.frameFailed(path, lines, err)
+		}
+		return true
+	})
+	in, out := p.compressed.in.Load(), p.compressed.out.Load()
+	if in > 0 {
+		p.ErrorLog.Info("compressed output",
+			"bytes", in,
+			"compressed", out,
+			"ratio", fmt.Sprintf("%.2f", float64(in)/float64(out)),
+		)
+	}
+}
+
+// openFramed opens path for code that writes output through openOutput,
+// such as the sorter, so that its lines go into the frames of the file.
+func (p *Processor) openFramed(path string) (io.WriteCloser, error) {
+	return frameWriter{p: p, path: path}, nil
+}
+
+type frameWriter struct {
+	p    *Processor
+	path string
+}
+
+func (w frameWriter) Write(b []byte) (int, error) {
+	if _, err := w.p.writeCompressed(w.path, string(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w frameWriter) Close() error { return nil }
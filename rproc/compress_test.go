@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestCompressedOutput checks that with OutputCompression the frames
+// appended to an output file decode to every matched line.
+func TestCompressedOutput(t *testing.T) {
+	const records = 10000
+	var data bytes.Buffer
+	for i := range records {
+		fmt.Fprintf(&data, `{"id":"t3_%d","subreddit":"golang"}`+"\n", i)
+	}
+
+	for _, mode := range []string{"zstd", "auto"} {
+		t.Run(mode, func(t *testing.T) {
+			out := t.TempDir()
+			p := &Processor{
+				Output:            out,
+				Files:             []string{"RC_compress.ndjson"},
+				Threads:           2,
+				OutputCompression: mode,
+				Fields:            []string{"subreddit"},
+				Values:            []string{"golang"},
+				FileFilter:        regexp.MustCompile(".*"),
+				Extensions:        []string{".ndjson"},
+				MatchMode:         "exact",
+				TimeField:         "created_utc",
+				OpenInput:         MemoryInput(map[string][]byte{"RC_compress.ndjson": data.Bytes()}),
+				ErrorLog:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if err := p.ProcessAndServe(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(filepath.Join(out, "RC_compress_golang.ndjson.zst"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			dec, err := zstd.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dec.Close()
+
+			seen := make(map[string]int)
+			scanner := bufio.NewScanner(dec)
+			for scanner.Scan() {
+				seen[jsoniter.Get(scanner.Bytes(), "id").ToString()]++
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+			for i := range records {
+				if id := fmt.Sprintf("t3_%d", i); seen[id] != 1 {
+					t.Errorf("record %s written %d times, want 1", id, seen[id])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ComputedField is a key added to every written record, holding Func
+// applied to the value of Field in that record. A missing field is passed
+// as the empty string.
+type ComputedField struct {
+	Name  string
+	Field string
+	Func  func(value string) any
+}
+
+// computeFuncs are the functions available to ParseComputedField.
+var computeFuncs = map[string]func(string) any{
+	// field_length is the length of the value in characters.
+	"field_length": func(v string) any { return utf8.RuneCountInString(v) },
+	// token_count is the number of whitespace-separated words.
+	"token_count": func(v string) any { return len(strings.Fields(v)) },
+}
+
+var computeExpr = regexp.MustCompile(`^\s*(\w+)\s*\(\s*([^()\s]+)\s*\)\s*$`)
+
+// ParseComputedField parses expr, a function applied to a field such as
+// "token_count(body)", into a ComputedField written under name.
+func ParseComputedField(name, expr string) (ComputedField, error) {
+	m := computeExpr.FindStringSubmatch(expr)
+	if m == nil {
+		return ComputedField{}, fmt.Errorf("invalid expression %q: expected function(field)", expr)
+	}
+	fn, ok := computeFuncs[m[1]]
+	if !ok {
+		return ComputedField{}, fmt.Errorf("unknown function %q: expected field_length or token_count", m[1])
+	}
+	return ComputedField{Name: name, Field: m[2], Func: fn}, nil
+}
+
+// addComputed appends the computed fields to a JSON object line. A
+// computed field named like a key the record already has replaces that
+// key's value where it stands instead, so that the key is not repeated.
+// Lines that are not objects are returned unchanged.
+func addComputed(line string, fields []ComputedField) string {
+	trimmed := strings.TrimRight(line, " \t\r")
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return line
+	}
+	values := make(map[string]string, len(fields))
+	collides := false
+	for _, f := range fields {
+		value, err := jsoniter.MarshalToString(f.Func(jsoniter.Get([]byte(trimmed), f.Field).ToString()))
+		if err != nil {
+			continue
+		}
+		values[f.Name] = value
+		if jsoniter.Get([]byte(trimmed), f.Name).ValueType() != jsoniter.InvalidValue {
+			collides = true
+		}
+	}
+	if collides {
+		return replaceComputed(line, trimmed, fields, values)
+	}
+
+	var b strings.Builder
+	b.WriteString(trimmed[:len(trimmed)-1])
+	sep := ","
+	if strings.TrimSpace(trimmed[1:len(trimmed)-1]) == "" {
+		sep = ""
+	}
+	for _, f := range fields {
+		value, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+		name, _ := jsoniter.MarshalToString(f.Name)
+		b.WriteString(sep + name + ":" + value)
+		sep = ","
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// replaceComputed rewrites the object trimmed with the computed values of
+// keys it already has in their place and the other computed fields after
+// its keys. line is returned unchanged if trimmed is not valid JSON.
+func replaceComputed(line, trimmed string, fields []ComputedField, values map[string]string) string {
+	iter := jsoniter.ParseString(jsoniter.ConfigDefault, trimmed)
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+
+	written := make(map[string]bool, len(fields))
+	more := false
+	field := func(key string, raw []byte) {
+		if more {
+			stream.WriteMore()
+		}
+		more = true
+		stream.WriteObjectField(key)
+		stream.Write(raw)
+	}
+	stream.WriteObjectStart()
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		raw := iter.SkipAndReturnBytes()
+		if value, ok := values[key]; ok {
+			if written[key] {
+				return true
+			}
+			written[key] = true
+			raw = []byte(value)
+		}
+		field(key, raw)
+		return true
+	})
+	for _, f := range fields {
+		if value, ok := values[f.Name]; ok && !written[f.Name] {
+			field(f.Name, []byte(value))
+		}
+	}
+	stream.WriteObjectEnd()
+	if iter.Error != nil || stream.Error != nil {
+		return line
+	}
+	return string(stream.Buffer())
+}
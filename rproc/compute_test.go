@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "testing"
+
+func TestAddComputed(t *testing.T) {
+	fields := []ComputedField{
+		mustComputed(t, "body_length", "field_length(body)"),
+		mustComputed(t, "words", "token_count(body)"),
+	}
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"appended", `{"id":"a","body":"hello there"}`, `{"id":"a","body":"hello there","body_length":11,"words":2}`},
+		{"empty object", `{}`, `{"body_length":0,"words":0}`},
+		{"not an object", `[1,2]`, `[1,2]`},
+		{"replaces existing key in place", `{"words":"x","id":"a","body":"a b c"}`, `{"words":3,"id":"a","body":"a b c","body_length":5}`},
+		{"replaces repeated key once", `{"words":7,"body":"a b","words":8}`, `{"words":2,"body":"a b","body_length":3}`},
+		{"invalid JSON", `{"words":1,"body":}`, `{"words":1,"body":}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addComputed(tt.line, fields); got != tt.want {
+				t.Errorf("addComputed(%s) = %s, want %s", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustComputed(t *testing.T, name, expr string) ComputedField {
+	t.Helper()
+	f, err := ParseComputedField(name, expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// isTerminal reports whether f is an interactive character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm writes the files about to be processed, their total size and
+// the filter settings to w, then reads a yes/no answer from r.
+func (p *Processor) confirm(files []string, r io.Reader, w io.Writer) (bool, error) {
+	var total int64
+	for _, file := range files {
+		size := "unknown size"
+		if !isURL(file) {
+			if n, err := p.inputSize(file); err == nil {
+				total += n
+				size = formatBytes(n)
+			}
+		}
+		fmt.Fprintf(w, "  %s (%s)\n", file, size)
+	}
+	fmt.Fprintf(w, "%d files, %s total\n", len(files), formatBytes(total))
+	fmt.Fprintf(w, "field: %s\n", strings.Join(p.Fields, ", "))
+	fmt.Fprintf(w, "match mode: %s\n", p.MatchMode)
+	fmt.Fprintf(w, "values (%d): %s\n", len(p.Values), strings.Join(p.Values, ", "))
+	if len(p.excludes) > 0 {
+		fmt.Fprintf(w, "excluded values (%d): %s\n", len(p.excludes), strings.Join(p.excludes, ", "))
+	}
+	if p.TruncateOutput {
+		fmt.Fprintf(w, "existing output files in %s will be overwritten\n", p.Output)
+	}
+	fmt.Fprint(w, "Start processing? [y/N] ")
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "slices"
+
+// valueSample collects the first distinct values of each filtered field
+// of one input file for DebugSample.
+type valueSample struct {
+	limit  int
+	fields []string
+	values map[string][]string
+	full   int
+}
+
+func newValueSample(fields []string, limit int) *valueSample {
+	return &valueSample{
+		limit:  limit,
+		fields: fields,
+		values: make(map[string][]string, len(fields)),
+	}
+}
+
+// add records the values of the sampled fields of line that have not been
+// seen yet. Empty values are skipped.
+func (s *valueSample) add(vf *valueFilter, file string, line []byte) {
+	if s.full == len(s.fields) {
+		return
+	}
+	for _, field := range s.fields {
+		seen := s.values[field]
+		if len(seen) >= s.limit {
+			continue
+		}
+		value := vf.fieldValue(file, line, field)
+		if value == "" || slices.Contains(seen, value) {
+			continue
+		}
+		s.values[field] = append(seen, value)
+		if len(s.values[field]) == s.limit {
+			s.full++
+		}
+	}
+}
+
+// logSample logs the values sampled from file, one entry per field.
+func (p *Processor) logSample(file string, s *valueSample) {
+	for _, field := range s.fields {
+		p.ErrorLog.Info("sampled field values",
+			"path", file,
+			"field", field,
+			"values", s.values[field],
+		)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// keyStore is a set of dedupe keys. When backed by a file, every new key
+// is appended as a quoted line so that it survives between runs.
+type keyStore struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+	file *os.File
+}
+
+// openKeyStore loads the keys recorded at path. An empty path yields an
+// in-memory store that only dedupes within a single run.
+func openKeyStore(path string, reset bool) (*keyStore, error) {
+	s := &keyStore{seen: make(map[string]struct{})}
+	if path == "" {
+		return s, nil
+	}
+
+	flag := os.O_APPEND | os.O_CREATE | os.O_RDWR
+	if reset {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("corrupt dedupe store %s: %w", path, err)
+		}
+		s.seen[key] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.file = f
+	return s, nil
+}
+
+// Claim reserves key for a line about to be written and reports whether
+// it was not already present. Lines with a claimed key are skipped, but
+// the key is only recorded in the file by Commit once the line has been
+// written, and Release frees it again if the line was dropped, so that a
+// later run does not skip a record that never reached the output.
+func (s *keyStore) Claim(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
+
+// Commit records a claimed key in the backing file.
+func (s *keyStore) Commit(key string) error {
+	if s.file == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.WriteString(strconv.Quote(key) + "\n")
+	return err
+}
+
+// Release frees a claimed key whose line was not written.
+func (s *keyStore) Release(key string) {
+	s.mu.Lock()
+	delete(s.seen, key)
+	s.mu.Unlock()
+}
+
+func (s *keyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.seen)
+}
+
+func (s *keyStore) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// dedupeOutputs removes repeated lines from every output file written
+// during the run, keeping the first occurrence of each line.
+func (p *Processor) dedupeOutputs() {
+	var total int64
+	p.dedupeOnClose.Range(func(key, _ any) bool {
+		path := key.(string)
+		removed, err := dedupeFile(path, p.lineEnding())
+		if errors.Is(err, fs.ErrNotExist) {
+			return true
+		}
+		if err != nil {
+			p.ErrorLog.Warn("failed to dedupe output file", "path", path, "err", err)
+			return true
+		}
+		if removed > 0 && p.checksums != nil {
+			// Reseed the running hash from the rewritten file.
+			p.checksums.discard(path)
+			if _, err := p.checksums.get(path); err != nil {
+				p.ErrorLog.Warn("failed to hash output file", "path", path, "err", err)
+			}
+		}
+		total += removed
+		return true
+	})
+	p.ErrorLog.Info("removed duplicate output lines", "count", total)
+}
+
+// dedupeFile rewrites path without repeated lines through a temporary
+// file that replaces it, and returns the number of lines removed. Lines
+// are compared by their SHA-256 so that memory grows with the number of
+// distinct lines rather than their length. Kept lines end with eol.
+func dedupeFile(path, eol string) (int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".dedupe-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(out.Name())
+
+	seen := make(map[[sha256.Size]byte]struct{})
+	var removed int64
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), 512<<20)
+	for scanner.Scan() {
+		sum := sha256.Sum256(scanner.Bytes())
+		if _, ok := seen[sum]; ok {
+			removed++
+			continue
+		}
+		seen[sum] = struct{}{}
+		w.Write(scanner.Bytes())
+		w.WriteString(eol)
+	}
+	if err := scanner.Err(); err != nil {
+		out.Close()
+		return 0, err
+	}
+	if removed == 0 {
+		out.Close()
+		return 0, nil
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return 0, err
+	}
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		out.Close()
+		return 0, err
+	}
+	if err := out.Close(); err != nil {
+		return 0, err
+	}
+	return removed, os.Rename(out.Name(), path)
+}
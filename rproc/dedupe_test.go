@@ -0,0 +1,236 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// TestKeyStore checks that only committed keys are kept between runs,
+// and that released keys can be claimed again.
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedupe.keys")
+	s, err := openKeyStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const odd = "t3_\"quoted\"\nkey"
+	for _, key := range []string{"t3_a", odd} {
+		if !s.Claim(key) {
+			t.Fatalf("first claim of %q failed", key)
+		}
+		if s.Claim(key) {
+			t.Errorf("second claim of %q succeeded", key)
+		}
+		if err := s.Commit(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.Claim("t3_dropped") {
+		t.Fatal("claim of t3_dropped failed")
+	}
+	s.Release("t3_dropped")
+	if !s.Claim("t3_dropped") {
+		t.Error("released key could not be claimed again")
+	}
+	if !s.Claim("t3_uncommitted") {
+		t.Fatal("claim of t3_uncommitted failed")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = openKeyStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Len(); n != 2 {
+		t.Errorf("reopened store holds %d keys, want 2", n)
+	}
+	for key, want := range map[string]bool{"t3_a": false, odd: false, "t3_dropped": true, "t3_uncommitted": true} {
+		if got := s.Claim(key); got != want {
+			t.Errorf("claim of %q after reopening = %v, want %v", key, got, want)
+		}
+	}
+	s.Close()
+
+	s, err = openKeyStore(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n := s.Len(); n != 0 {
+		t.Errorf("reset store holds %d keys, want 0", n)
+	}
+}
+
+func TestKeyStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedupe.keys")
+	if err := os.WriteFile(path, []byte("\"t3_a\"\nnot quoted\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openKeyStore(path, false); err == nil {
+		t.Error("corrupt store opened without error")
+	}
+}
+
+// TestDedupeStoreAcrossRuns checks that a record written by one run is
+// skipped by the next one using the same store.
+func TestDedupeStoreAcrossRuns(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "dedupe.keys")
+	run := func(data string) []string {
+		out := t.TempDir()
+		p := &Processor{
+			Output:      out,
+			Files:       []string{"RC_dedupe.ndjson"},
+			Threads:     1,
+			Fields:      []string{"subreddit"},
+			Values:      []string{"golang"},
+			FileFilter:  regexp.MustCompile(".*"),
+			Extensions:  []string{".ndjson"},
+			MatchMode:   "exact",
+			TimeField:   "created_utc",
+			DedupeField: "id",
+			DedupeStore: store,
+			OpenInput:   MemoryInput(map[string][]byte{"RC_dedupe.ndjson": []byte(data)}),
+			ErrorLog:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+		if err := p.ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+		return outputIDs(t, out)
+	}
+
+	first := run(`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+		`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+		`{"id":"t1_b","subreddit":"golang"}` + "\n")
+	if len(first) != 2 {
+		t.Fatalf("first run matched %v, want t1_a and t1_b once each", first)
+	}
+	second := run(`{"id":"t1_b","subreddit":"golang"}` + "\n" +
+		`{"id":"t1_c","subreddit":"golang"}` + "\n")
+	if len(second) != 1 || second[0] != "t1_c" {
+		t.Errorf("second run matched %v, want only t1_c", second)
+	}
+}
+
+// outputIDs returns the ids of the records in the output files below dir.
+func outputIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	var ids []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".ndjson" {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for line := range strings.Lines(string(b)) {
+			ids = append(ids, jsoniter.Get([]byte(line), "id").ToString())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// TestDedupeFile checks that repeated lines are removed keeping the first
+// occurrence and the file mode, and that a file without repeats is left
+// alone.
+func TestDedupeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.ndjson")
+	if err := os.WriteFile(path, []byte("b\na\nb\r\nc\na\n\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := dedupeFile(path, "\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d lines, want 3", removed)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "b\r\na\r\nc\r\n\r\n"; string(b) != want {
+		t.Errorf("deduped file = %q, want %q", b, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode after dedupe = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	if removed, err := dedupeFile(path, "\n"); err != nil || removed != 0 {
+		t.Errorf("second dedupe removed %d lines, %v, want 0", removed, err)
+	}
+	if b2, _ := os.ReadFile(path); string(b2) != string(b) {
+		t.Errorf("file without repeats was rewritten to %q", b2)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the directory, want only the output", len(entries))
+	}
+}
+
+// TestDedupeOnClose checks that a run appending the same matches to an
+// existing output file leaves each line in it once.
+func TestDedupeOnClose(t *testing.T) {
+	out := t.TempDir()
+	data := []byte(`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+		`{"id":"t1_b","subreddit":"golang"}` + "\n")
+	for range 2 {
+		p := &Processor{
+			Output:        out,
+			Files:         []string{"RC_close.ndjson"},
+			Threads:       1,
+			Fields:        []string{"subreddit"},
+			Values:        []string{"golang"},
+			FileFilter:    regexp.MustCompile(".*"),
+			Extensions:    []string{".ndjson"},
+			MatchMode:     "exact",
+			TimeField:     "created_utc",
+			DedupeOnClose: true,
+			OpenInput:     MemoryInput(map[string][]byte{"RC_close.ndjson": data}),
+			ErrorLog:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+		if err := p.ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := outputIDs(t, out); strings.Join(ids, ",") != "t1_a,t1_b" {
+		t.Errorf("output holds %v, want t1_a and t1_b once each", ids)
+	}
+}
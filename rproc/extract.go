@@ -0,0 +1,179 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// defaultExtractMaxValues caps the distinct values kept per field in
+// extract and aggregate mode when ExtractMaxValues is zero.
+const defaultExtractMaxValues = 10_000_000
+
+// valueSet counts distinct strings up to a limit.
+type valueSet struct {
+	mu     sync.Mutex
+	limit  int
+	values map[string]int64
+	full   bool
+}
+
+func (s *valueSet) add(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[value]; !ok && len(s.values) >= s.limit {
+		s.full = true
+		return
+	}
+	s.values[value]++
+}
+
+// newExtractSets returns an empty set per configured field.
+func (p *Processor) newExtractSets() map[string]*valueSet {
+	limit := p.ExtractMaxValues
+	if limit <= 0 {
+		limit = defaultExtractMaxValues
+	}
+	sets := make(map[string]*valueSet, len(p.Fields))
+	for _, field := range p.Fields {
+		sets[field] = &valueSet{limit: limit, values: make(map[string]int64)}
+	}
+	return sets
+}
+
+// extract adds the values of the configured fields of line to their sets,
+// as numeric buckets when aggregating with an AggregateBucket width.
+// Missing and empty values are not collected.
+func (p *Processor) extract(file string, line []byte) {
+	for field, set := range p.extracted {
+		var value string
+		switch {
+		case field == FilenameField:
+			value = inputName(file)
+		case p.MatchMode == "aggregate" && p.AggregateBucket > 0:
+			v := jsoniter.Get(line, field)
+			if v.ValueType() != jsoniter.NumberValue {
+				continue
+			}
+			bucket := math.Floor(v.ToFloat64()/p.AggregateBucket) * p.AggregateBucket
+			value = strconv.FormatFloat(bucket, 'f', -1, 64)
+		default:
+			value = jsoniter.Get(line, field).ToString()
+		}
+		if value != "" {
+			set.add(value)
+		}
+	}
+}
+
+// writeExtracted writes the collected values of each field to the output
+// directory: the sorted distinct values to <field>_values.txt in extract
+// mode, or value,count rows to <field>_histogram.csv in aggregate mode.
+func (p *Processor) writeExtracted() {
+	for field, set := range p.extracted {
+		var path string
+		var err error
+		if p.MatchMode == "aggregate" {
+			path = filepath.Join(p.Output, field+"_histogram.csv")
+			err = writeHistogram(path, set.values, p.AggregateBucket > 0)
+		} else {
+			path = filepath.Join(p.Output, field+"_values.txt")
+			err = writeLines(path, slices.Sorted(maps.Keys(set.values)))
+		}
+		if err != nil {
+			p.ErrorLog.Error("failed to write extracted values", "path", path, "err", err)
+			continue
+		}
+		p.ErrorLog.Info("wrote extracted values", "path", path, "count", len(set.values))
+		if set.full {
+			p.ErrorLog.Warn("extracted values reached the limit, list is incomplete",
+				"field", field,
+				"limit", set.limit,
+			)
+		}
+	}
+}
+
+func writeLines(path string, lines []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeHistogram writes counts as CSV, ordered by bucket for numeric
+// buckets and by descending count otherwise.
+func writeHistogram(path string, counts map[string]int64, numeric bool) error {
+	keys := slices.Collect(maps.Keys(counts))
+	if numeric {
+		slices.SortFunc(keys, func(a, b string) int {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return cmp.Compare(x, y)
+		})
+	} else {
+		slices.SortFunc(keys, func(a, b string) int {
+			return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b))
+		})
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"value", "count"})
+	for _, key := range keys {
+		w.Write([]string{key, strconv.FormatInt(counts[key], 10)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
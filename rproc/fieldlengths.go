@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// fieldLengths samples the first FieldLengths lines of each file and
+// writes the distribution of the byte lengths of every filtered field, and
+// of the lines themselves, to w. Missing fields count as empty.
+func (p *Processor) fieldLengths(files []string, w io.Writer) error {
+	lengths := make([][]int, len(p.Fields)+1)
+	empty := make([]int64, len(p.Fields))
+	var records int64
+	for _, file := range files {
+		if p.shuttingDown() {
+			return ErrProcessClosed
+		}
+		n, err := p.readSample(file, p.FieldLengths, func(line []byte) bool {
+			if len(line) == 0 {
+				return false
+			}
+			for i, field := range p.Fields {
+				value := p.rawFieldValue(file, line, field)
+				if value == "" {
+					empty[i]++
+				}
+				lengths[i] = append(lengths[i], len(value))
+			}
+			lengths[len(p.Fields)] = append(lengths[len(p.Fields)], len(line))
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("sampling %s: %w", file, err)
+		}
+		records += n
+	}
+	if records == 0 {
+		return fmt.Errorf("no lines to measure")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FIELD\tEMPTY\tP50\tP90\tP99\tMAX\n")
+	for i, l := range lengths {
+		slices.Sort(l)
+		name, emptyShare := "(line)", "-"
+		if i < len(p.Fields) {
+			name = p.Fields[i]
+			emptyShare = fmt.Sprintf("%.1f%%", 100*float64(empty[i])/float64(records))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n", name, emptyShare,
+			percentile(l, 0.5), percentile(l, 0.9), percentile(l, 0.99), l[len(l)-1])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nlengths in bytes of %d records sampled from %d files\n", records, len(files))
+	return err
+}
+
+// rawFieldValue returns the value of field in line as written, before
+// HTML unescaping, falling back to its FieldAliases like matching does.
+func (p *Processor) rawFieldValue(file string, line []byte, field string) string {
+	for _, name := range append([]string{field}, p.FieldAliases[field]...) {
+		var value string
+		if name == FilenameField {
+			value = inputName(file)
+		} else {
+			value = jsoniter.Get(line, name).ToString()
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// percentile returns the nearest-rank q-quantile of the sorted lengths.
+func percentile(sorted []int, q float64) int {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
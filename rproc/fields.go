@@ -0,0 +1,127 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// fieldStats counts how often a field was present in the sampled records
+// and which JSON types it held.
+type fieldStats struct {
+	present int64
+	types   map[string]int64
+}
+
+// listFields samples the first ListFields lines of each file and writes a
+// table of the observed fields with their fill rate and types to w.
+func (p *Processor) listFields(files []string, w io.Writer) error {
+	fields := make(map[string]*fieldStats)
+	var records int64
+	for _, file := range files {
+		if p.shuttingDown() {
+			return ErrProcessClosed
+		}
+		n, err := p.sampleFields(file, fields)
+		if err != nil {
+			return fmt.Errorf("sampling %s: %w", file, err)
+		}
+		records += n
+	}
+	if records == 0 {
+		return fmt.Errorf("no records sampled")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FIELD\tFILL\tTYPES\n")
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		fs := fields[name]
+		types := slices.SortedFunc(maps.Keys(fs.types), func(a, b string) int {
+			return cmp.Or(cmp.Compare(fs.types[b], fs.types[a]), strings.Compare(a, b))
+		})
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%s\n", name, 100*float64(fs.present)/float64(records), strings.Join(types, ", "))
+	}
+	fmt.Fprintf(tw, "\n%d records sampled from %d files\n", records, len(files))
+	return tw.Flush()
+}
+
+// sampleFields adds the fields of up to ListFields records of file to
+// fields and returns the number of records read.
+func (p *Processor) sampleFields(file string, fields map[string]*fieldStats) (int64, error) {
+	return p.readSample(file, p.ListFields, func(line []byte) bool {
+		var record map[string]any
+		if err := jsoniter.Unmarshal(line, &record); err != nil {
+			p.ErrorLog.Debug("skipping line that is not a JSON object", "path", file, "err", err)
+			return false
+		}
+		p.collectFields("", record, fields)
+		return true
+	})
+}
+
+// collectFields records the keys of obj under prefix, descending into
+// nested objects when ListNested is set.
+func (p *Processor) collectFields(prefix string, obj map[string]any, fields map[string]*fieldStats) {
+	for key, v := range obj {
+		name := prefix + key
+		fs, ok := fields[name]
+		if !ok {
+			fs = &fieldStats{types: make(map[string]int64)}
+			fields[name] = fs
+		}
+		fs.present++
+		fs.types[jsonTypeName(v)]++
+
+		if nested, ok := v.(map[string]any); ok && p.ListNested {
+			p.collectFields(name+".", nested, fields)
+		}
+	}
+}
+
+// jsonTypeName returns the type mode name of a decoded JSON value.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,320 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// valueFilter matches records whose fields match one of a set of values
+// under a match mode.
+type valueFilter struct {
+	fields  []string
+	values  []string
+	mode    string
+	regexes []*regexp.Regexp
+
+	// unescape decodes HTML entities in field values before matching.
+	unescape bool
+
+	// aliases lists the fields tried in turn when a field is empty.
+	aliases map[string][]string
+
+	// emptyField is the EmptyField policy, and empty counts the lines
+	// whose fields were all empty under the count policy.
+	emptyField string
+	empty      atomic.Int64
+
+	// foldCase marks regexes compiled case-insensitively, so that combine
+	// keeps the flag.
+	foldCase bool
+
+	// matcher tests the value of each field, or the whole line in jq
+	// mode. combine replaces it with a faster one for some modes.
+	matcher Matcher
+}
+
+// ahoCorasickMinValues is the number of values from which partial mode
+// searches for all of them at once rather than one strings.Contains each.
+const ahoCorasickMinValues = 16
+
+// newAutomatonMatcher builds the automaton used in partial mode.
+func newAutomatonMatcher(values []string) automatonMatcher {
+	lower := make([]string, len(values))
+	for i, value := range values {
+		lower[i] = strings.ToLower(value)
+	}
+	return automatonMatcher{ac: newAhoCorasick(lower), values: values}
+}
+
+// splitExcludes separates values starting with "-" from the values to
+// match and strips the dash. A leading "\-" escapes a value that starts
+// with a literal dash, such as some usernames.
+func splitExcludes(values []string) (include, exclude []string) {
+	for _, value := range values {
+		switch {
+		case strings.HasPrefix(value, `\-`):
+			include = append(include, value[1:])
+		case strings.HasPrefix(value, "-") && len(value) > 1:
+			exclude = append(exclude, value[1:])
+		default:
+			include = append(include, value)
+		}
+	}
+	return include, exclude
+}
+
+// newValueFilter compiles values for the given match mode.
+func newValueFilter(fields, values []string, mode string) (*valueFilter, error) {
+	vf := &valueFilter{fields: fields, values: values, mode: mode}
+	var (
+		exprs []jqExpr
+		tests []lengthTest
+	)
+	for _, value := range values {
+		switch mode {
+		case "regex":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", value, err)
+			}
+			vf.regexes = append(vf.regexes, re)
+		case "word":
+			vf.regexes = append(vf.regexes, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(value)+`\b`))
+		case "jq":
+			e, err := compileJQ(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jq expression %q: %w", value, err)
+			}
+			exprs = append(exprs, e)
+		case "type":
+			if _, ok := valueTypeNames[strings.ToLower(value)]; !ok {
+				return nil, fmt.Errorf("unknown value type %q in type match mode", value)
+			}
+		case "array_len":
+			t, err := parseLengthTest(value)
+			if err != nil {
+				return nil, err
+			}
+			tests = append(tests, t)
+		}
+	}
+	switch mode {
+	case "regex", "word":
+		vf.matcher = regexMatcher{regexes: vf.regexes, values: values}
+	case "partial":
+		if len(values) >= ahoCorasickMinValues {
+			vf.matcher = newAutomatonMatcher(values)
+		} else {
+			vf.matcher = partialMatcher(values)
+		}
+	case "jq":
+		vf.matcher = jqMatcher{exprs: exprs, values: values}
+	case "array_len":
+		vf.matcher = lengthMatcher{tests: tests, values: values}
+	default:
+		vf.matcher = exactMatcher(values)
+	}
+	return vf, nil
+}
+
+// combine replaces the per-value tests of the exact, partial, word and
+// regex modes with a single test per field: a map lookup for exact mode,
+// an Aho-Corasick automaton for partial mode, and one alternation of all
+// values, each in its own capture group, for word and regex mode. In the
+// latter two, when a field matches several values the one reported is the
+// leftmost match in the field rather than the first in values.
+func (vf *valueFilter) combine() error {
+	switch vf.mode {
+	case "partial":
+		if _, ok := vf.matcher.(automatonMatcher); !ok {
+			vf.matcher = newAutomatonMatcher(vf.values)
+		}
+		return nil
+	case "exact":
+		vf.matcher = newExactLookup(vf.values)
+		return nil
+	case "word", "regex":
+	default:
+		return nil
+	}
+
+	alternatives := make([]string, len(vf.values))
+	groups := make([]int, len(vf.values))
+	group := 1
+	for i, value := range vf.values {
+		if vf.mode == "regex" {
+			alternatives[i] = "(" + value + ")"
+			groups[i] = group
+			group += 1 + vf.regexes[i].NumSubexp()
+			continue
+		}
+		alternatives[i] = "(" + regexp.QuoteMeta(value) + ")"
+		groups[i] = group
+		group++
+	}
+
+	pattern := strings.Join(alternatives, "|")
+	switch {
+	case vf.mode == "word":
+		pattern = `(?i)\b(?:` + pattern + `)\b`
+	case vf.foldCase:
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("combining values: %w", err)
+	}
+	vf.matcher = alternationMatcher{re: re, groups: groups, values: vf.values}
+	return nil
+}
+
+// match checks each field of line against the values and returns the
+// first value that matches any field. In jq mode the whole line is
+// matched once instead.
+func (vf *valueFilter) match(file string, line []byte) (string, bool) {
+	if vf.mode == "jq" {
+		return vf.matcher.Match("", line)
+	}
+	allEmpty := true
+	for _, field := range vf.fields {
+		fieldVal := vf.fieldValue(file, line, field)
+		if fieldVal == "" {
+			continue
+		}
+		allEmpty = false
+		if val, ok := vf.matcher.Match(fieldVal, line); ok {
+			return val, true
+		}
+	}
+	if allEmpty {
+		// Empty fields are only matched when there is nothing else to
+		// match, so "match" cannot add matches to lines with a value.
+		switch vf.emptyField {
+		case "count":
+			vf.empty.Add(1)
+		case "match":
+			return vf.matcher.Match("", line)
+		}
+	}
+	return "", false
+}
+
+// fieldValue returns the value of field in line as it is compared
+// against the values, or that of the first of its aliases that is not
+// empty.
+func (vf *valueFilter) fieldValue(file string, line []byte, field string) string {
+	value := vf.lookup(file, line, field)
+	if value != "" {
+		return value
+	}
+	for _, alias := range vf.aliases[field] {
+		if value := vf.lookup(file, line, alias); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// lookup returns the value of a single field in line.
+func (vf *valueFilter) lookup(file string, line []byte, field string) string {
+	switch {
+	case vf.mode == "array_len":
+		if field == FilenameField {
+			return ""
+		}
+		v := jsoniter.Get(line, field)
+		switch v.ValueType() {
+		case jsoniter.ArrayValue:
+			return strconv.Itoa(v.Size())
+		case jsoniter.InvalidValue, jsoniter.NilValue:
+			return ""
+		}
+		// A field holding something else is not empty, so the
+		// empty_field policy leaves it alone, and has no length.
+		return notArray
+	case field == FilenameField && vf.mode == "type":
+		return "string"
+	case field == FilenameField:
+		return inputName(file)
+	case vf.mode == "type":
+		return valueTypeName(jsoniter.Get(line, field).ValueType())
+	case vf.unescape:
+		return html.UnescapeString(jsoniter.Get(line, field).ToString())
+	default:
+		return jsoniter.Get(line, field).ToString()
+	}
+}
+
+// lengthTest compares an array length against n, as parsed from a value
+// such as ">5" in array_len mode.
+type lengthTest struct {
+	op string
+	n  int
+}
+
+// lengthOps lists the comparison operators, longest first so that ">="
+// is not read as ">".
+var lengthOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+func parseLengthTest(value string) (lengthTest, error) {
+	s := strings.TrimSpace(value)
+	op := "="
+	for _, o := range lengthOps {
+		if rest, ok := strings.CutPrefix(s, o); ok {
+			op, s = o, strings.TrimSpace(rest)
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return lengthTest{}, fmt.Errorf("invalid array length comparison %q, expected e.g. >5 or 0", value)
+	}
+	return lengthTest{op: op, n: n}, nil
+}
+
+func (t lengthTest) match(length int) bool {
+	switch t.op {
+	case ">=":
+		return length >= t.n
+	case "<=":
+		return length <= t.n
+	case "!=":
+		return length != t.n
+	case ">":
+		return length > t.n
+	case "<":
+		return length < t.n
+	default:
+		return length == t.n
+	}
+}
@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "testing"
+
+// TestEmptyFieldMatch checks that the match policy tests the empty value
+// only when every field is empty.
+func TestEmptyFieldMatch(t *testing.T) {
+	vf, err := newValueFilter([]string{"subreddit", "author"}, []string{"^$", "^bob$"}, "regex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vf.emptyField = "match"
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{"subreddit":"","author":""}`, true},
+		{`{}`, true},
+		{`{"subreddit":"golang"}`, false},
+		{`{"subreddit":"","author":"alice"}`, false},
+		{`{"subreddit":"","author":"bob"}`, true},
+	}
+	for _, tt := range tests {
+		if _, got := vf.match("RC_test.zst", []byte(tt.line)); got != tt.want {
+			t.Errorf("match(%s) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	vf.emptyField = "skip"
+	if _, ok := vf.match("RC_test.zst", []byte(`{}`)); ok {
+		t.Error("skip policy matched a line without the fields")
+	}
+}
+
+// TestEmptyFieldArrayLen checks that in array_len mode the match policy
+// treats a missing array as empty, but never matches a field that holds
+// something other than an array.
+func TestEmptyFieldArrayLen(t *testing.T) {
+	vf, err := newValueFilter([]string{"tags"}, []string{"0", "<5"}, "array_len")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vf.emptyField = "match"
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{}`, true},
+		{`{"tags":null}`, true},
+		{`{"tags":[]}`, true},
+		{`{"tags":["a","b"]}`, true},
+		{`{"tags":[1,2,3,4,5,6]}`, false},
+		{`{"tags":"a"}`, false},
+		{`{"tags":""}`, false},
+		{`{"tags":0}`, false},
+		{`{"tags":3}`, false},
+		{`{"tags":false}`, false},
+		{`{"tags":{}}`, false},
+	}
+	for _, tt := range tests {
+		if _, got := vf.match("RC_test.zst", []byte(tt.line)); got != tt.want {
+			t.Errorf("match(%s) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// flatten rewrites a JSON object line with its nested objects and arrays
+// expanded into top-level keys, joining the path with "_" and indexing
+// array elements from 0, so {"a":{"b":1},"c":[2]} becomes
+// {"a_b":1,"c_0":2}. Empty objects and arrays are kept as values. When a
+// flattened key repeats one already written, e.g. a nested author_flair.text
+// next to a top-level author_flair_text, the first one is kept. Lines that
+// are not valid JSON objects are returned unchanged.
+func flatten(line string) string {
+	iter := jsoniter.ParseString(jsoniter.ConfigDefault, line)
+	if iter.WhatIsNext() != jsoniter.ObjectValue {
+		return line
+	}
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+
+	f := flattener{stream: stream, seen: make(map[string]struct{})}
+	stream.WriteObjectStart()
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		f.value(iter, key)
+		return true
+	})
+	stream.WriteObjectEnd()
+	if iter.Error != nil || stream.Error != nil {
+		return line
+	}
+	return string(stream.Buffer())
+}
+
+// flattener writes the leaves of a JSON value as fields of one object.
+type flattener struct {
+	stream *jsoniter.Stream
+	seen   map[string]struct{}
+}
+
+func (f *flattener) value(iter *jsoniter.Iterator, key string) {
+	n := 0
+	switch iter.WhatIsNext() {
+	case jsoniter.ObjectValue:
+		iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+			f.value(iter, key+"_"+field)
+			n++
+			return true
+		})
+		if n == 0 {
+			f.field(key, []byte("{}"))
+		}
+	case jsoniter.ArrayValue:
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			f.value(iter, key+"_"+strconv.Itoa(n))
+			n++
+			return true
+		})
+		if n == 0 {
+			f.field(key, []byte("[]"))
+		}
+	default:
+		f.field(key, iter.SkipAndReturnBytes())
+	}
+}
+
+func (f *flattener) field(key string, raw []byte) {
+	if _, ok := f.seen[key]; ok {
+		return
+	}
+	if len(f.seen) > 0 {
+		f.stream.WriteMore()
+	}
+	f.seen[key] = struct{}{}
+	f.stream.WriteObjectField(key)
+	f.stream.Write(raw)
+}
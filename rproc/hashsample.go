@@ -0,0 +1,50 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"hash/fnv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// inHashSample reports whether line belongs to the 1-in-HashSample sample.
+// The choice depends only on the salted hash of HashSampleField, so the
+// same records are kept whatever the file order, thread count or shard.
+// Lines without the field are never sampled.
+func (p *Processor) inHashSample(line []byte) bool {
+	if p.HashSample <= 1 {
+		return true
+	}
+	key := jsoniter.Get(line, p.HashSampleField).ToString()
+	if key == "" {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(p.HashSampleSalt))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return h.Sum64()%uint64(p.HashSample) == 0
+}
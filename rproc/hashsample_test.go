@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestInHashSample(t *testing.T) {
+	p := &Processor{HashSample: 10, HashSampleField: "id"}
+	line := func(i int) []byte { return fmt.Appendf(nil, `{"id":"t1_%d"}`, i) }
+
+	const records = 20000
+	var kept []int
+	for i := range records {
+		if p.inHashSample(line(i)) {
+			kept = append(kept, i)
+		}
+	}
+	if n := len(kept); n < records/10*8/10 || n > records/10*12/10 {
+		t.Errorf("kept %d of %d records, want about a tenth", n, records)
+	}
+	for _, i := range kept[:min(len(kept), 50)] {
+		if !p.inHashSample(line(i)) {
+			t.Fatalf("record %d not kept the second time", i)
+		}
+	}
+
+	p.HashSampleSalt = "other"
+	same := 0
+	for _, i := range kept {
+		if p.inHashSample(line(i)) {
+			same++
+		}
+	}
+	if same > len(kept)/2 {
+		t.Errorf("%d of %d records kept with a different salt, want a different sample", same, len(kept))
+	}
+
+	if p.inHashSample([]byte(`{"title":"no id"}`)) {
+		t.Error("record without the field was sampled")
+	}
+	p.HashSample = 1
+	if !p.inHashSample([]byte(`{}`)) {
+		t.Error("hash_sample 1 dropped a record")
+	}
+}
+
+// TestHashSampleDeterministic checks that the sample does not depend on
+// how the records are split over files or how many threads read them.
+func TestHashSampleDeterministic(t *testing.T) {
+	var all []byte
+	parts := make(map[string][]byte)
+	for i := range 2000 {
+		line := fmt.Appendf(nil, `{"id":"t1_%d","subreddit":"golang"}`+"\n", i)
+		all = append(all, line...)
+		name := fmt.Sprintf("RC_part%d.ndjson", i%7)
+		parts[name] = append(parts[name], line...)
+	}
+	run := func(inputs map[string][]byte, threads int) []string {
+		var (
+			mu  sync.Mutex
+			ids []string
+		)
+		var files []string
+		for name := range inputs {
+			files = append(files, name)
+		}
+		p := &Processor{
+			Output:          t.TempDir(),
+			Files:           files,
+			Threads:         threads,
+			Fields:          []string{"subreddit"},
+			Values:          []string{"golang"},
+			FileFilter:      regexp.MustCompile(".*"),
+			Extensions:      []string{".ndjson"},
+			MatchMode:       "exact",
+			TimeField:       "created_utc",
+			HashSample:      20,
+			HashSampleField: "id",
+			HashSampleSalt:  "2024",
+			OpenInput:       MemoryInput(inputs),
+			OnMatch: func(_, _ string, line []byte) {
+				mu.Lock()
+				ids = append(ids, jsoniter.Get(line, "id").ToString())
+				mu.Unlock()
+			},
+			ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+		if err := p.ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	whole := run(map[string][]byte{"RC_all.ndjson": all}, 1)
+	if len(whole) == 0 {
+		t.Fatal("sample is empty")
+	}
+	if split := run(parts, 4); !slices.Equal(whole, split) {
+		t.Errorf("sample of split input differs: %d records, want the same %d", len(split), len(whole))
+	}
+}
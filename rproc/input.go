@@ -0,0 +1,382 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	maxHTTPRetries = 5
+)
+
+// isURL reports whether the input refers to a remote HTTP(S) resource
+// rather than a local path.
+func isURL(input string) bool {
+	u, err := url.Parse(input)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// inputName returns the base name of a local path or the last element
+// of a URL path, without any query string. Zip archive entries are named
+// by their path in the archive with slashes replaced by underscores.
+func inputName(input string) string {
+	if _, entry, ok := splitZipEntry(input); ok {
+		return strings.ReplaceAll(entry, "/", "_")
+	}
+	if isURL(input) {
+		u, _ := url.Parse(input)
+		return path.Base(u.Path)
+	}
+	return filepath.Base(input)
+}
+
+// isZstd reports whether an input is expected to be zstd-compressed,
+// judging by its extension. The content decides in the end; see decode.
+func isZstd(input string) bool {
+	return strings.EqualFold(filepath.Ext(inputName(input)), ".zst")
+}
+
+// isZstdMagic reports whether head starts a zstd frame, either a regular
+// or a skippable one.
+func isZstdMagic(head []byte) bool {
+	magic := binary.LittleEndian.Uint32(head)
+	return magic == 0xfd2fb528 || magic&0xfffffff0 == 0x184d2a50
+}
+
+// decode returns the records of input, decompressing it when its content
+// is zstd whatever its extension, and warning when the extension of file
+// says otherwise. Inputs too short to tell go by the extension. The
+// returned function releases the decoder. size is the length of input, or
+// -1 if unknown.
+func (p *Processor) decode(file string, input io.Reader, size int64) (io.Reader, func(), error) {
+	reader := bufio.NewReader(input)
+	head, err := reader.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	compressed := isZstd(file)
+	if len(head) == 4 && isZstdMagic(head) != compressed {
+		compressed = !compressed
+		p.ErrorLog.Warn("file extension does not match its content",
+			"path", file,
+			"zstd", compressed,
+		)
+	}
+	if !compressed {
+		return reader, func() {}, nil
+	}
+	// The decoder carries on across frame boundaries until EOF, so files
+	// made of several concatenated frames are read completely.
+	opts := slices.Clip(zstdOpts)
+	if p.zstdDict != nil {
+		opts = append(opts, zstd.WithDecoderDicts(p.zstdDict))
+	}
+	if p.ConcurrentDecodeSize > 0 {
+		opts = append(opts, zstd.WithDecoderConcurrency(p.decoderConcurrency(size)))
+	}
+	zstdReader, err := zstd.NewReader(reader, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zstdReader, zstdReader.Close, nil
+}
+
+// decoderConcurrency returns the number of decoder goroutines for a zstd
+// input of size bytes under ConcurrentDecodeSize. The CPUs are shared by
+// the files read at once, so with fewer files than threads each decoder
+// gets more of them.
+func (p *Processor) decoderConcurrency(size int64) int {
+	if size >= 0 && size < p.ConcurrentDecodeSize {
+		return 1
+	}
+	threads := p.parallelism
+	if threads == 0 {
+		threads = p.Threads
+	}
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+	return max(runtime.GOMAXPROCS(0)/threads, 1)
+}
+
+// MemoryInput returns an OpenInput function that serves the given contents
+// by file name, so that a Processor can run on inputs built in memory,
+// e.g. in tests. Contents are decompressed by the same rules as files, so
+// a name ending in .zst must hold zstd data.
+func MemoryInput(files map[string][]byte) func(ctx context.Context, name string) (io.ReadCloser, int64, error) {
+	return func(ctx context.Context, name string) (io.ReadCloser, int64, error) {
+		b, ok := files[name]
+		if !ok {
+			return nil, 0, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return io.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+	}
+}
+
+// loadZstdDict reads ZstdDict and checks that the decoder accepts it, so
+// that a bad dictionary fails the run before any file is processed.
+func (p *Processor) loadZstdDict() error {
+	dict, err := os.ReadFile(p.ZstdDict)
+	if err != nil {
+		return err
+	}
+	d, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
+	if err != nil {
+		return err
+	}
+	d.Close()
+	p.zstdDict = dict
+	return nil
+}
+
+// openInput opens a local file or zip archive entry or streams a remote
+// URL, or calls OpenInput if it is set. The returned size is -1 when the
+// total length is unknown.
+func (p *Processor) openInput(ctx context.Context, input string) (io.ReadCloser, int64, error) {
+	if p.OpenInput != nil {
+		return p.OpenInput(ctx, input)
+	}
+	if archive, entry, ok := splitZipEntry(input); ok {
+		return p.openZipEntry(archive, entry)
+	}
+	if !isURL(input) {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, 0, err
+		}
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	r := &httpReader{
+		ctx:    ctx,
+		client: http.DefaultClient,
+		url:    input,
+		log:    p.ErrorLog,
+	}
+	resp, err := r.get(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unexpected status fetching %s: %s", input, resp.Status)
+	}
+	r.body = resp.Body
+	r.ranges = resp.Header.Get("Accept-Ranges") == "bytes"
+	return r, resp.ContentLength, nil
+}
+
+// httpReader streams a response body and, when the server supports byte
+// ranges, transparently resumes from the last read offset if the
+// connection drops.
+type httpReader struct {
+	ctx     context.Context
+	client  *http.Client
+	url     string
+	log     *slog.Logger
+	body    io.ReadCloser
+	offset  int64
+	ranges  bool
+	retries int
+}
+
+func (r *httpReader) get(offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	setTraceparent(r.ctx, req)
+	return r.client.Do(req)
+}
+
+func (r *httpReader) Read(b []byte) (int, error) {
+	n, err := r.body.Read(b)
+	r.offset += int64(n)
+	if err == nil || errors.Is(err, io.EOF) || !r.ranges || r.retries >= maxHTTPRetries || r.ctx.Err() != nil {
+		return n, err
+	}
+
+	r.retries++
+	r.log.Warn("connection dropped, resuming download",
+		"url", r.url,
+		"offset", r.offset,
+		"attempt", r.retries,
+		"err", err,
+	)
+	resp, rerr := r.get(r.offset)
+	if rerr != nil {
+		return n, err
+	}
+	if resp.StatusCode != http.StatusPartialContent || contentRangeStart(resp.Header.Get("Content-Range")) != r.offset {
+		// The server did not send the requested range, so the download
+		// starts over and the part already read is skipped rather than
+		// appended.
+		r.log.Warn("server did not resume at offset, restarting download",
+			"url", r.url,
+			"offset", r.offset,
+			"status", resp.Status,
+		)
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			if resp, rerr = r.get(0); rerr != nil {
+				return n, err
+			}
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return n, err
+		}
+		if _, cerr := io.CopyN(io.Discard, resp.Body, r.offset); cerr != nil {
+			resp.Body.Close()
+			return n, err
+		}
+	}
+	r.body.Close()
+	r.body = resp.Body
+	return n, nil
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-199/200", or -1 if it cannot be parsed.
+func contentRangeStart(h string) int64 {
+	rest, ok := strings.CutPrefix(h, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+func (r *httpReader) Close() error {
+	return r.body.Close()
+}
+
+// checkOverlap rejects an input directory that is, contains or lies inside
+// the output directory, since the walk would pick up freshly written
+// output and writes could collide with the inputs being read.
+func checkOverlap(input, output string) error {
+	if input == "" || isURL(input) {
+		return nil
+	}
+	in, err := filepath.Abs(input)
+	if err != nil {
+		return err
+	}
+	out, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	if within(in, out) || within(out, in) {
+		return fmt.Errorf("input %s and output %s overlap; use separate directories", input, output)
+	}
+	return nil
+}
+
+// within reports whether path is dir or below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readSample calls fn for the lines at the start of file until it has
+// accepted n of them or the file ends, and returns the number accepted.
+// The line passed to fn is only valid for the duration of the call.
+func (p *Processor) readSample(file string, n int, fn func(line []byte) bool) (int64, error) {
+	input, size, err := p.openInput(context.Background(), file)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+
+	reader, release, err := p.decode(file, input, size)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	scanner, _ := newRecordScanner(reader)
+	var accepted int64
+	for accepted < int64(n) && scanner.Scan() {
+		if fn(scanner.Bytes()) {
+			accepted++
+		}
+	}
+	return accepted, scanner.Err()
+}
+
+// isArrayInput reports whether file holds a single top-level JSON array
+// rather than one record per line.
+func (p *Processor) isArrayInput(file string) (bool, error) {
+	input, size, err := p.openInput(context.Background(), file)
+	if err != nil {
+		return false, err
+	}
+	defer input.Close()
+
+	reader, release, err := p.decode(file, input, size)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	_, isArray := newRecordScanner(reader)
+	return isArray, nil
+}
@@ -0,0 +1,97 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdFrame compresses the records with ids from first to last as one
+// independent zstd frame.
+func zstdFrame(t *testing.T, first, last int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := first; i <= last; i++ {
+		fmt.Fprintf(enc, `{"id":"t3_%d","subreddit":"golang"}`+"\n", i)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestConcatenatedZstdFrames checks that a file made of several zstd
+// frames written independently and concatenated, as some dump tools do,
+// is read to the end rather than stopping after the first frame.
+func TestConcatenatedZstdFrames(t *testing.T) {
+	const records = 1000
+	data := append(zstdFrame(t, 0, records/2-1), zstdFrame(t, records/2, records-1)...)
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	p := &Processor{
+		Output:     t.TempDir(),
+		Files:      []string{"RC_concat.zst"},
+		Threads:    1,
+		Fields:     []string{"subreddit"},
+		Values:     []string{"golang"},
+		FileFilter: regexp.MustCompile(".*"),
+		Extensions: []string{".zst"},
+		MatchMode:  "exact",
+		TimeField:  "created_utc",
+		OpenInput:  MemoryInput(map[string][]byte{"RC_concat.zst": data}),
+		OnMatch: func(_, _ string, line []byte) {
+			mu.Lock()
+			seen[jsoniter.Get(line, "id").ToString()]++
+			mu.Unlock()
+		},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != records {
+		t.Errorf("matched %d distinct records, want %d", len(seen), records)
+	}
+	for i := range records {
+		if id := fmt.Sprintf("t3_%d", i); seen[id] != 1 {
+			t.Errorf("record %s matched %d times, want 1", id, seen[id])
+		}
+	}
+}
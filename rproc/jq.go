@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"github.com/itchyny/gojq"
+)
+
+// jqExpr is a jq predicate compiled with gojq and evaluated against a
+// decoded JSON record.
+type jqExpr struct {
+	code *gojq.Code
+}
+
+// compileJQ parses and compiles src, so that syntax errors and unknown
+// functions or variables fail the run before any file is read.
+func compileJQ(src string) (jqExpr, error) {
+	query, err := gojq.Parse(src)
+	if err != nil {
+		return jqExpr{}, err
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return jqExpr{}, err
+	}
+	return jqExpr{code: code}, nil
+}
+
+// match reports whether the first output of the expression for record is
+// truthy: anything but false and null. An expression with no output, or
+// one that fails, such as indexing a number, does not match.
+func (e jqExpr) match(record any) bool {
+	v, ok := e.code.Run(record).Next()
+	if !ok {
+		return false
+	}
+	if _, ok := v.(error); ok {
+		return false
+	}
+	return v != nil && v != false
+}
@@ -0,0 +1,191 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const jqTestRecord = `{
+	"score": 120,
+	"ratio": -0.5,
+	"subreddit": "golang",
+	"title": "",
+	"edited": false,
+	"deleted": null,
+	"tags": ["a", "b", "c"],
+	"media": {"type": "video", "size": 3},
+	"a b": 1,
+	"x]y": 2,
+	"nested": [{"x": [10, 20]}]
+}`
+
+func TestJQEval(t *testing.T) {
+	var record any
+	if err := jsoniter.UnmarshalFromString(jqTestRecord, &record); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Paths.
+		{`.score`, true},
+		{`.missing`, false},
+		{`.edited`, false},
+		{`.deleted`, false},
+		{`.title`, true},
+		{`.`, true},
+		{`.media.type == "video"`, true},
+		{`.["media"]["type"] == "video"`, true},
+		{`.media["type"] == "video"`, true},
+		{`."a b" == 1`, true},
+		{`.["a b"] == 1`, true},
+		{`.[ "x]y" ] == 2`, true},
+		{`.tags[0] == "a"`, true},
+		{`.tags[-1] == "c"`, true},
+		{`.tags[3] == null`, true},
+		{`.tags.[1] == "b"`, true},
+		{`.nested[0].x[1] == 20`, true},
+
+		// Indexing a number, a string or an object by position is an error
+		// in jq, so such records don't match.
+		{`.score[0] == null`, false},
+		{`.subreddit.name == null`, false},
+		{`.media[0] == null`, false},
+
+		// Literals and numbers.
+		{`.score == 120`, true},
+		{`.score == 1.2e2`, true},
+		{`.score > 1e-3`, true},
+		{`.ratio == -0.5`, true},
+		{`.ratio == -.5`, true},
+		{`.ratio < -0`, true},
+		{`.ratio > -1`, true},
+		{`.score>-1`, true},
+		{`0.5 == .5`, true},
+		{`"golang" == .subreddit`, true},
+		{`"a\"b" == "a\"b"`, true},
+		{`true`, true},
+		{`false`, false},
+		{`null`, false},
+		{`0`, true},
+
+		// Comparisons across types follow jq's order:
+		// null < false < true < numbers < strings < arrays < objects.
+		{`null < false`, true},
+		{`false < true`, true},
+		{`true < 0`, true},
+		{`1000 < ""`, true},
+		{`"z" < .tags`, true},
+		{`.tags < .media`, true},
+		{`.missing < .edited`, true},
+		{`.score != "120"`, true},
+		{`.edited == false`, true},
+		{`.edited != null`, true},
+		{`"abc" < "abd"`, true},
+		{`"ab" < "abc"`, true},
+		{`.tags == .tags`, true},
+		{`.tags > .nested[0].x`, true},
+		{`.nested[0].x < .nested[0].x`, false},
+		{`.media == .media`, true},
+		{`.media <= .media`, true},
+		{`.media < .media`, false},
+		{`.media > .media`, false},
+
+		// Boolean operators and precedence.
+		{`.score > 100 and .subreddit == "golang"`, true},
+		{`.score > 100 and .subreddit == "rust"`, false},
+		{`.score < 100 or .subreddit == "golang"`, true},
+		{`false or false`, false},
+		{`true or false and false`, true},
+		{`(true or false) and false`, false},
+		{`.edited | not`, true},
+		{`.score | not`, false},
+		{`.score > 100 and .subreddit == "rust" | not`, true},
+		{`.score > 100 or false | not`, false},
+		{`(.subreddit == "rust" | not) and .score > 100`, true},
+		{`.edited | not | not`, false},
+
+		// Functions and generators. Only the first output counts.
+		{`.tags | length == 3`, true},
+		{`any(.tags[]; . == "b")`, true},
+		{`.tags[] == "b"`, false},
+		{`.subreddit | startswith("go")`, true},
+		{`.subreddit | test("^GO"; "i")`, true},
+		{`select(.score > 100)`, true},
+		{`select(.score > 200)`, false},
+		{`empty`, false},
+		{`.media | has("size")`, true},
+	}
+	for _, tt := range tests {
+		e, err := compileJQ(tt.expr)
+		if err != nil {
+			t.Errorf("compileJQ(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := e.match(record); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestJQCompileErrors checks that expressions gojq cannot parse or
+// compile are rejected when the filter is built.
+func TestJQCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{``, "missing query"},
+		{`.score >`, "unexpected EOF"},
+		{`.score == 1 and`, "unexpected EOF"},
+		{`.score == 1 2`, `unexpected token "2"`},
+		{`(.score == 1`, "unexpected EOF"},
+		{`.score == 1)`, `unexpected token ")"`},
+		{`.a |`, "unexpected EOF"},
+		{`.a == "x`, "unterminated string literal"},
+		{`.["a"`, "unexpected EOF"},
+		{`.a == 'x'`, `unexpected token "'"`},
+		{`1.2.3 == 1`, `invalid token "1.2."`},
+		{`.[x]`, "function not defined: x/0"},
+		{`lenght > 1`, "function not defined: lenght/0"},
+		{`startswith("a"; "b")`, "function not defined: startswith/2"},
+		{`.score > $min`, "variable not defined: $min"},
+	}
+	for _, tt := range tests {
+		_, err := compileJQ(tt.expr)
+		if err == nil {
+			t.Errorf("compileJQ(%s) succeeded, want error containing %q", tt.expr, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("compileJQ(%s) = %v, want error containing %q", tt.expr, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// recordScanner yields the records of an input one at a time, like
+// bufio.Scanner does for lines. Size is the number of input bytes the last
+// record took up, including its line ending.
+type recordScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Size() int
+	Err() error
+}
+
+// newRecordScanner returns a line scanner for r, or an arrayScanner when
+// the first non-whitespace byte of r is "[", for exports that wrap all
+// records in one top-level JSON array instead of writing one per line.
+func newRecordScanner(r io.Reader) (recordScanner, bool) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	if startsWithArray(br) {
+		return &arrayScanner{iter: jsoniter.Parse(jsoniter.ConfigDefault, br, 64<<10)}, true
+	}
+	return newLineScanner(br), false
+}
+
+// lineScanner scans lines like bufio.Scanner with ScanLines, which drops
+// the line ending, and keeps the length of each line as read, so that
+// byte offsets stay right for lines ending in CRLF.
+type lineScanner struct {
+	*bufio.Scanner
+	size int
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r)}
+	s.Buffer(make([]byte, 64<<10), 512<<20)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			s.size = advance
+		}
+		return advance, token, err
+	})
+	return s
+}
+
+func (s *lineScanner) Size() int {
+	return s.size
+}
+
+// startsWithArray peeks past leading whitespace without consuming it, so
+// that line numbers of line-delimited files stay the same.
+func startsWithArray(br *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		buf, _ := br.Peek(n)
+		if len(buf) < n {
+			return false
+		}
+		switch buf[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// arrayScanner streams the elements of a top-level JSON array, each
+// compacted onto a single line.
+type arrayScanner struct {
+	iter *jsoniter.Iterator
+	buf  bytes.Buffer
+	err  error
+}
+
+func (s *arrayScanner) Scan() bool {
+	if s.err != nil || !s.iter.ReadArray() {
+		return false
+	}
+	raw := s.iter.SkipAndReturnBytes()
+	if s.iter.Error != nil {
+		return false
+	}
+	s.buf.Reset()
+	if err := json.Compact(&s.buf, raw); err != nil {
+		s.err = err
+		return false
+	}
+	return true
+}
+
+func (s *arrayScanner) Bytes() []byte {
+	return s.buf.Bytes()
+}
+
+// Size returns the length of the compacted element and a line ending, as
+// the element has no place in the input that offsets could point to.
+func (s *arrayScanner) Size() int {
+	return s.buf.Len() + 1
+}
+
+func (s *arrayScanner) Err() error {
+	err := s.err
+	if err == nil && s.iter.Error != nil && !errors.Is(s.iter.Error, io.EOF) {
+		err = s.iter.Error
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON array: %w", err)
+	}
+	return nil
+}
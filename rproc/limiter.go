@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// limiter bounds the number of running workers by a limit that may be
+// changed while they run, and hands each worker the lowest free ID for
+// the per-worker statistics. Lowering the limit lets running workers
+// finish their current file; no new ones start until enough have ended.
+type limiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	running int
+	free    []int
+	next    int
+}
+
+func newLimiter(limit int) *limiter {
+	l := &limiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until fewer than limit workers are running or ctx is
+// done, and returns the ID of the new worker.
+func (l *limiter) acquire(ctx context.Context) (int, error) {
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		l.cond.Broadcast()
+		l.mu.Unlock()
+	})
+	defer stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.running >= l.limit {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		l.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	l.running++
+
+	if len(l.free) == 0 {
+		l.next++
+		return l.next - 1, nil
+	}
+	i := slices.Index(l.free, slices.Min(l.free))
+	id := l.free[i]
+	l.free = slices.Delete(l.free, i, i+1)
+	return id, nil
+}
+
+// release ends the worker with the given ID.
+func (l *limiter) release(id int) {
+	l.mu.Lock()
+	l.running--
+	l.free = append(l.free, id)
+	l.cond.Broadcast()
+	l.mu.Unlock()
+}
+
+// active returns the number of workers running.
+func (l *limiter) active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running
+}
+
+// setLimit changes the number of workers allowed to run at once and
+// returns the previous limit.
+func (l *limiter) setLimit(limit int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.limit
+	l.limit = limit
+	l.cond.Broadcast()
+	return prev
+}
+
+// SetThreads changes the number of files processed at once while the
+// processor is running. Zero means one per CPU. Lowering it lets running
+// workers finish their current file first. It has no effect before
+// processing has started.
+func (p *Processor) SetThreads(threads int) {
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	p.mu.Lock()
+	workers := p.workers
+	p.mu.Unlock()
+	if workers == nil {
+		p.ErrorLog.Warn("ignoring thread count change before processing has started")
+		return
+	}
+
+	from := workers.setLimit(threads)
+	p.ErrorLog.Info("changed thread count", "from", from, "to", threads)
+}
@@ -0,0 +1,206 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Matcher decides whether a record matches. Match is called with the value
+// of each field in turn, extracted as for the match mode, and the raw line,
+// and returns the value the record matched, which names its output file.
+// Match is called concurrently from the workers, and line is only valid
+// for the duration of the call.
+type Matcher interface {
+	Match(field string, line []byte) (value string, ok bool)
+}
+
+// MatcherFunc adapts an ordinary function to the Matcher interface.
+type MatcherFunc func(field string, line []byte) (string, bool)
+
+func (f MatcherFunc) Match(field string, line []byte) (string, bool) {
+	return f(field, line)
+}
+
+// exactMatcher compares the field with each value, ignoring case. Type
+// mode uses it too, on the name of the field's JSON type.
+type exactMatcher []string
+
+func (m exactMatcher) Match(field string, _ []byte) (string, bool) {
+	for _, value := range m {
+		if strings.EqualFold(field, value) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// exactLookup maps the lowercased values to the values, for exact mode
+// with CombineValues.
+type exactLookup map[string]string
+
+func (m exactLookup) Match(field string, _ []byte) (string, bool) {
+	value, ok := m[strings.ToLower(field)]
+	return value, ok
+}
+
+func newExactLookup(values []string) exactLookup {
+	m := make(exactLookup, len(values))
+	for _, value := range values {
+		key := strings.ToLower(value)
+		if _, ok := m[key]; !ok {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// partialMatcher looks for each value in the field, ignoring case.
+type partialMatcher []string
+
+func (m partialMatcher) Match(field string, _ []byte) (string, bool) {
+	lower := strings.ToLower(field)
+	for _, value := range m {
+		if strings.Contains(lower, strings.ToLower(value)) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// automatonMatcher looks for all values in the field at once.
+type automatonMatcher struct {
+	ac     *ahoCorasick
+	values []string
+}
+
+func (m automatonMatcher) Match(field string, _ []byte) (string, bool) {
+	if i, ok := m.ac.first(strings.ToLower(field)); ok {
+		return m.values[i], true
+	}
+	return "", false
+}
+
+// regexMatcher tests the field against one regex per value, in regex and
+// word mode.
+type regexMatcher struct {
+	regexes []*regexp.Regexp
+	values  []string
+}
+
+func (m regexMatcher) Match(field string, _ []byte) (string, bool) {
+	for i, re := range m.regexes {
+		if re.MatchString(field) {
+			return m.values[i], true
+		}
+	}
+	return "", false
+}
+
+// alternationMatcher tests the field against a single regex with one
+// capture group per value, at group index groups[i] for values[i].
+type alternationMatcher struct {
+	re     *regexp.Regexp
+	groups []int
+	values []string
+}
+
+func (m alternationMatcher) Match(field string, _ []byte) (string, bool) {
+	loc := m.re.FindStringSubmatchIndex(field)
+	if loc == nil {
+		return "", false
+	}
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 {
+			return m.values[i], true
+		}
+	}
+	return "", false
+}
+
+// notArray is the value looked up in array_len mode for a field that is
+// present but not an array. lengthMatcher never matches it.
+const notArray = "(not an array)"
+
+// lengthMatcher compares the length of an array field with each value in
+// array_len mode.
+type lengthMatcher struct {
+	tests  []lengthTest
+	values []string
+}
+
+func (m lengthMatcher) Match(field string, _ []byte) (string, bool) {
+	if field == notArray {
+		return "", false
+	}
+	length, _ := strconv.Atoi(field)
+	for i, t := range m.tests {
+		if t.match(length) {
+			return m.values[i], true
+		}
+	}
+	return "", false
+}
+
+// jqMatcher decodes the line and returns the first expression that
+// evaluates to a truthy value. It ignores the field, and lines that are
+// not valid JSON never match.
+type jqMatcher struct {
+	exprs  []jqExpr
+	values []string
+}
+
+func (m jqMatcher) Match(_ string, line []byte) (string, bool) {
+	var record any
+	if err := jsoniter.Unmarshal(line, &record); err != nil {
+		return "", false
+	}
+	for i, e := range m.exprs {
+		if e.match(record) {
+			return m.values[i], true
+		}
+	}
+	return "", false
+}
+
+// customMatcher wraps Matcher so that matches of values missing from
+// Values, which have no output counters, are dropped and counted.
+func (p *Processor) customMatcher() Matcher {
+	return MatcherFunc(func(field string, line []byte) (string, bool) {
+		value, ok := p.Matcher.Match(field, line)
+		if !ok {
+			return "", false
+		}
+		if _, known := p.matchCounts[value]; !known {
+			p.unknownValues.Add(1)
+			return "", false
+		}
+		return value, true
+	})
+}
@@ -0,0 +1,180 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// scannedBatch is how many lines a worker reads between updates of
+	// the shared line count, to keep the counter off the per-line path.
+	scannedBatch = 4096
+
+	// A file of at least matchRateMinLines lines of which none or at least
+	// highMatchRate matched gets a warning.
+	matchRateMinLines = 100
+	highMatchRate     = 0.99
+)
+
+// totalMatches returns the number of records matched so far.
+func (p *Processor) totalMatches() int64 {
+	var n int64
+	for _, count := range p.matchCounts {
+		n += count.Load()
+	}
+	return n
+}
+
+// Matched returns the number of records matched so far, and Scanned the
+// number of input lines read. Scanned is updated by the workers in
+// batches, so it is only exact once processing has finished.
+func (p *Processor) Matched() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.totalMatches()
+}
+
+func (p *Processor) Scanned() int64 {
+	return p.scanned.Load()
+}
+
+// matchRate returns matched/lines, or nil if no line has been read.
+func matchRate(lines, matched int64) *float64 {
+	if lines == 0 {
+		return nil
+	}
+	rate := float64(matched) / float64(lines)
+	return &rate
+}
+
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.2f%%", 100*rate)
+}
+
+// rateFlag returns "high" for a file of at least matchRateMinLines lines
+// of which nearly all matched, "none" for one of which none did, and ""
+// otherwise.
+func rateFlag(lines, matched int64) string {
+	switch {
+	case lines < matchRateMinLines:
+		return ""
+	case float64(matched)/float64(lines) >= highMatchRate:
+		return "high"
+	case matched == 0:
+		return "none"
+	}
+	return ""
+}
+
+// logMatchRate logs the share of the lines of file that matched and warns
+// when nearly all or none of them did, which usually means the filter is
+// not doing what was intended. With RunReport the rate is also kept for
+// report.json.
+func (p *Processor) logMatchRate(file string, lines, matched int64) {
+	if p.extracted != nil {
+		return
+	}
+	rate := matchRate(lines, matched)
+	flag := rateFlag(lines, matched)
+	if p.RunReport {
+		p.mu.Lock()
+		p.fileRates = append(p.fileRates, fileRate{Path: file, Lines: lines, Matched: matched, MatchRate: rate, Flag: flag})
+		p.mu.Unlock()
+	}
+	if rate == nil {
+		return
+	}
+	args := []any{"path", file, "lines", lines, "matched", matched, "rate", formatRate(*rate)}
+	switch flag {
+	case "high":
+		p.ErrorLog.Warn("nearly every record in file matched", args...)
+	case "none":
+		p.ErrorLog.Warn("no record in file matched", args...)
+	default:
+		p.ErrorLog.Info("file match rate", args...)
+	}
+}
+
+// fileRate is the entry of an input file in report.json. Flag is "high"
+// or "none" as returned by rateFlag.
+type fileRate struct {
+	Path      string   `json:"path"`
+	Lines     int64    `json:"lines"`
+	Matched   int64    `json:"matched"`
+	MatchRate *float64 `json:"match_rate"`
+	Flag      string   `json:"flag,omitempty"`
+}
+
+// runReport is the content of report.json. MatchRate is null when no line
+// was read and in the extract and aggregate modes, which match nothing.
+type runReport struct {
+	Lines      int64      `json:"lines_scanned"`
+	Matched    int64      `json:"lines_matched"`
+	MatchRate  *float64   `json:"match_rate"`
+	Files      []fileRate `json:"files"`
+	Elapsed    float64    `json:"elapsed_seconds"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
+
+// writeReport replaces report.json with the match rates of the run
+// started at start, overall and per input file in path order.
+func (p *Processor) writeReport(start time.Time) {
+	path := filepath.Join(p.Output, "report.json")
+	r := runReport{
+		Lines:      p.scanned.Load(),
+		Matched:    p.totalMatches(),
+		Files:      []fileRate{},
+		Elapsed:    time.Since(start).Seconds(),
+		FinishedAt: time.Now().UTC(),
+	}
+	if p.extracted == nil {
+		r.MatchRate = matchRate(r.Lines, r.Matched)
+	}
+	p.mu.Lock()
+	r.Files = append(r.Files, p.fileRates...)
+	p.mu.Unlock()
+	slices.SortStableFunc(r.Files, func(a, b fileRate) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	b, err := jsoniter.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path+".tmp", b, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		p.ErrorLog.Warn("failed to write run report", "path", path, "err", err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// lineNumber extracts the number stored in field of line, which may also
+// be given as a numeric string.
+func lineNumber(line []byte, field string) (float64, bool) {
+	v := jsoniter.Get(line, field)
+	switch v.ValueType() {
+	case jsoniter.NumberValue:
+		return v.ToFloat64(), true
+	case jsoniter.StringValue:
+		n, err := strconv.ParseFloat(v.ToString(), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// inNumberRange reports whether line falls inside the configured inclusive
+// range of NumberField. Lines without a number there are outside any
+// range.
+func (p *Processor) inNumberRange(line []byte) bool {
+	if p.NumberMin == nil && p.NumberMax == nil {
+		return true
+	}
+	n, ok := lineNumber(line, p.NumberField)
+	if !ok {
+		return false
+	}
+	if p.NumberMin != nil && n < *p.NumberMin {
+		return false
+	}
+	if p.NumberMax != nil && n > *p.NumberMax {
+		return false
+	}
+	return true
+}
+
+// prefiltered reports whether records are checked against the time
+// window, number range or hash sample before their values are matched.
+// The records rejected by the value filter are then logged as well, so
+// the end of the run shows why each record was skipped.
+func (p *Processor) prefiltered() bool {
+	return !p.TimeStart.IsZero() || !p.TimeEnd.IsZero() ||
+		p.NumberMin != nil || p.NumberMax != nil || p.HashSample > 1
+}
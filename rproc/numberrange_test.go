@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestInNumberRange(t *testing.T) {
+	lo, hi := 10.0, 20.0
+	p := &Processor{NumberField: "score", NumberMin: &lo, NumberMax: &hi}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{"score":9.99}`, false},
+		{`{"score":10}`, true},
+		{`{"score":"15"}`, true},
+		{`{"score":20}`, true},
+		{`{"score":20.5}`, false},
+		{`{"score":"high"}`, false},
+		{`{"score":null}`, false},
+		{`{}`, false},
+	}
+	for _, tt := range tests {
+		if got := p.inNumberRange([]byte(tt.line)); got != tt.want {
+			t.Errorf("inNumberRange(%s) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	p.NumberMax = nil
+	if !p.inNumberRange([]byte(`{"score":1e9}`)) {
+		t.Error("range without an upper bound rejected a high score")
+	}
+}
+
+// TestPrefilterCounts checks that the time window, the number range and
+// the value filter must all pass, and that each record is counted under
+// the first check that rejects it.
+func TestPrefilterCounts(t *testing.T) {
+	lines := []string{
+		`{"id":"keep1","created_utc":1650000000,"score":1500,"subreddit":"golang"}`,
+		`{"id":"keep2","created_utc":"2022-12-31T23:59:59Z","score":"2000","subreddit":"golang"}`,
+		`{"id":"old","created_utc":1600000000,"score":1500,"subreddit":"golang"}`,
+		`{"id":"old_low","created_utc":1600000000,"score":5,"subreddit":"rust"}`,
+		`{"id":"new","created_utc":"2023-01-01T00:00:00Z","score":1500,"subreddit":"golang"}`,
+		`{"id":"low","created_utc":1650000000,"score":999,"subreddit":"golang"}`,
+		`{"id":"unscored","created_utc":1650000000,"subreddit":"golang"}`,
+		`{"id":"other","created_utc":1650000000,"score":1500,"subreddit":"rust"}`,
+	}
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line+"\n"...)
+	}
+
+	var (
+		mu   sync.Mutex
+		kept []string
+	)
+	minScore := 1000.0
+	p := &Processor{
+		Output:      t.TempDir(),
+		Files:       []string{"RS_2022.ndjson"},
+		Threads:     1,
+		Fields:      []string{"subreddit"},
+		Values:      []string{"golang"},
+		FileFilter:  regexp.MustCompile(".*"),
+		Extensions:  []string{".ndjson"},
+		MatchMode:   "exact",
+		TimeField:   "created_utc",
+		TimeStart:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeEnd:     time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC),
+		NumberField: "score",
+		NumberMin:   &minScore,
+		OpenInput:   MemoryInput(map[string][]byte{"RS_2022.ndjson": data}),
+		OnMatch: func(_, _ string, line []byte) {
+			mu.Lock()
+			kept = append(kept, jsoniter.Get(line, "id").ToString())
+			mu.Unlock()
+		},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(kept, []string{"keep1", "keep2"}) {
+		t.Errorf("kept %v, want keep1 and keep2", kept)
+	}
+	counts := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{"time window", p.timeSkipped.Load(), 3},
+		{"number range", p.numSkipped.Load(), 2},
+		{"values", p.unmatched.Load(), 1},
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			t.Errorf("%d records skipped by the %s, want %d", c.got, c.name, c.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "sync"
+
+// orderedOutput holds the matched lines of each input file until every
+// file before it in discovery order has finished, so that output is
+// written in the same order on every run regardless of which worker
+// finishes first.
+type orderedOutput struct {
+	mu      sync.Mutex
+	order   []string
+	next    int
+	buffers map[string][]outputRecord
+	done    map[string]bool
+
+	// flushMu keeps files from being written concurrently, which would
+	// interleave their lines again.
+	flushMu sync.Mutex
+}
+
+func newOrderedOutput(files []string) *orderedOutput {
+	return &orderedOutput{
+		order:   files,
+		buffers: make(map[string][]outputRecord),
+		done:    make(map[string]bool),
+	}
+}
+
+func (o *orderedOutput) add(r outputRecord) {
+	o.mu.Lock()
+	o.buffers[r.file] = append(o.buffers[r.file], r)
+	o.mu.Unlock()
+}
+
+// finish marks file as done and returns the buffered lines of the files
+// that can now be written, in order. With all set, every remaining file is
+// released, including ones that never started.
+func (o *orderedOutput) finish(file string, all bool) []outputRecord {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done[file] = true
+	var ready []outputRecord
+	for o.next < len(o.order) && (all || o.done[o.order[o.next]]) {
+		name := o.order[o.next]
+		ready = append(ready, o.buffers[name]...)
+		delete(o.buffers, name)
+		o.next++
+	}
+	return ready
+}
+
+// flushOrdered marks file as done and writes every buffered line that is
+// next in order, or everything that is left with all set.
+func (p *Processor) flushOrdered(file string, all bool) {
+	o := p.ordered
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+	for _, r := range o.finish(file, all) {
+		p.write(r.file, r.value, r.lineNo, r.line)
+	}
+}
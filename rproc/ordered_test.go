@@ -0,0 +1,119 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestOrderedOutputFinish(t *testing.T) {
+	o := newOrderedOutput([]string{"a", "b", "c", "d"})
+	for _, file := range []string{"c", "a", "b", "a", "d"} {
+		o.add(outputRecord{file: file, line: file})
+	}
+	lines := func(rs []outputRecord) string {
+		var s string
+		for _, r := range rs {
+			s += r.line
+		}
+		return s
+	}
+
+	steps := []struct {
+		file string
+		all  bool
+		want string
+	}{
+		{"c", false, ""},
+		{"a", false, "aa"},
+		{"b", false, "bc"},
+		{"d", true, "d"},
+	}
+	for _, s := range steps {
+		if got := lines(o.finish(s.file, s.all)); got != s.want {
+			t.Errorf("finish(%s, %v) released %q, want %q", s.file, s.all, got, s.want)
+		}
+	}
+
+	o = newOrderedOutput([]string{"a", "b", "c"})
+	o.add(outputRecord{file: "c", line: "c"})
+	if got := lines(o.finish("c", true)); got != "c" {
+		t.Errorf("finish with all released %q, want %q", got, "c")
+	}
+}
+
+// TestOrderedOutputDedupe checks that with ordered output the copy of a
+// record that dedupe_field keeps is the one in the first file, however
+// the workers are scheduled.
+func TestOrderedOutputDedupe(t *testing.T) {
+	inputs := make(map[string][]byte)
+	var files []string
+	for i := range 8 {
+		name := fmt.Sprintf("RC_%d.ndjson", i)
+		files = append(files, name)
+		inputs[name] = fmt.Appendf(nil, `{"id":"t1_dup","n":%d,"subreddit":"golang"}`+"\n", i)
+	}
+	for range 5 {
+		out := t.TempDir()
+		p := &Processor{
+			Output:        out,
+			Files:         files,
+			Threads:       4,
+			Fields:        []string{"subreddit"},
+			Values:        []string{"golang"},
+			FileFilter:    regexp.MustCompile(".*"),
+			Extensions:    []string{".ndjson"},
+			MatchMode:     "exact",
+			TimeField:     "created_utc",
+			DedupeField:   "id",
+			OrderedOutput: true,
+			OpenInput:     MemoryInput(inputs),
+			ErrorLog:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		}
+		if err := p.ProcessAndServe(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			if filepath.Ext(e.Name()) == ".ndjson" {
+				names = append(names, e.Name())
+			}
+		}
+		if !slices.Equal(names, []string{"RC_0_golang.ndjson"}) {
+			t.Fatalf("output files %v, want only RC_0_golang.ndjson", names)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "errors"
+
+// errOutputLimit is the cause the run is cancelled with once
+// MaxOutputBytes is reached. Unlike ErrDiskFull it is not an error of the
+// run, so Serve does not return it.
+var errOutputLimit = errors.New("process: output limit reached")
+
+// reserveOutput accounts for size bytes about to be written and reports
+// whether they still fit under MaxOutputBytes. The first line that does
+// not fit stops the run the same way a full disk does; it and every line
+// after it are dropped and counted.
+func (p *Processor) reserveOutput(size int) bool {
+	if p.MaxOutputBytes <= 0 {
+		return true
+	}
+	if !p.outputFull.Load() {
+		if p.outputBytes.Add(int64(size)) <= p.MaxOutputBytes {
+			return true
+		}
+		p.outputBytes.Add(-int64(size))
+		if p.outputFull.CompareAndSwap(false, true) {
+			p.ErrorLog.Info("output limit reached, stopping",
+				"max_output_bytes", p.MaxOutputBytes,
+				"written", p.outputBytes.Load(),
+			)
+			p.cancelRun(errOutputLimit)
+		}
+	}
+	p.limitDropped.Add(1)
+	return false
+}
@@ -0,0 +1,96 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestMaxOutputBytes checks that a run stops without an error once the
+// next line would go over MaxOutputBytes, and that what it wrote stays
+// under the limit.
+func TestMaxOutputBytes(t *testing.T) {
+	var data []byte
+	for i := range 1000 {
+		data = fmt.Appendf(data, `{"id":"t1_%03d","subreddit":"golang"}`+"\n", i)
+	}
+	lineSize := int64(len(`{"id":"t1_000","subreddit":"golang"}` + "\n"))
+
+	out := t.TempDir()
+	p := &Processor{
+		Output:         out,
+		Files:          []string{"RC_limit.ndjson"},
+		Threads:        1,
+		Fields:         []string{"subreddit"},
+		Values:         []string{"golang"},
+		FileFilter:     regexp.MustCompile(".*"),
+		Extensions:     []string{".ndjson"},
+		MatchMode:      "exact",
+		TimeField:      "created_utc",
+		MaxOutputBytes: 10*lineSize + lineSize/2,
+		OpenInput:      MemoryInput(map[string][]byte{"RC_limit.ndjson": data}),
+		ErrorLog:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatalf("run stopped by the output limit returned %v", err)
+	}
+	info, err := os.Stat(filepath.Join(out, "RC_limit_golang.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 10*lineSize {
+		t.Errorf("wrote %d bytes, want the %d of the first 10 lines", info.Size(), 10*lineSize)
+	}
+	if ids := outputIDs(t, out); len(ids) != 10 || ids[0] != "t1_000" || ids[9] != "t1_009" {
+		t.Errorf("output holds %v, want t1_000 to t1_009", ids)
+	}
+	if p.limitDropped.Load() == 0 {
+		t.Error("no lines counted as dropped by the limit")
+	}
+}
+
+func TestMaxOutputBytesAtomic(t *testing.T) {
+	p := &Processor{
+		Output:         t.TempDir(),
+		Files:          []string{"RC_limit.ndjson"},
+		Fields:         []string{"subreddit"},
+		Values:         []string{"golang"},
+		FileFilter:     regexp.MustCompile(".*"),
+		MatchMode:      "exact",
+		MaxOutputBytes: 1 << 20,
+		AtomicOutput:   true,
+		OpenInput:      MemoryInput(nil),
+		ErrorLog:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err == nil {
+		t.Error("output limit combined with atomic output was accepted")
+	}
+}
@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+)
+
+// dominantFileSize is the size from which an input holding most of the
+// input bytes gets a hint about splitting it. Smaller runs end before the
+// idle workers matter.
+const dominantFileSize = 256 << 20
+
+// logParallelism logs when there are fewer files, or tasks once seekable
+// inputs are split into chunks, than threads, which leaves the extra
+// workers idle, and hints at splitting an input that holds most of the
+// bytes, since only one worker reads each file that is not split.
+func (p *Processor) logParallelism(f []string, tasks, threads int) {
+	if len(f) > 0 && tasks < threads {
+		p.ErrorLog.Info("fewer input files than threads",
+			"threads", threads,
+			"files", len(f),
+			"workers", tasks,
+		)
+	}
+	if threads < 2 || tasks > len(f) {
+		return
+	}
+	var total, largest int64
+	var largestFile string
+	for _, file := range f {
+		if isURL(file) {
+			continue
+		}
+		size, err := p.inputSize(file)
+		if err != nil {
+			continue
+		}
+		total += size
+		if size > largest {
+			largest, largestFile = size, file
+		}
+	}
+	if largest < dominantFileSize || 2*largest < total {
+		return
+	}
+	p.ErrorLog.Info("one input file holds most of the data and is read by a single worker; "+
+		"to spread it, run several processes over parts of it with -offset and -length, "+
+		"or set seekable_chunk_mb if it is in the zstd seekable format",
+		"path", largestFile,
+		"share", fmt.Sprintf("%.0f%%", 100*float64(largest)/float64(total)),
+	)
+}
@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import "context"
+
+// Pause stops the workers before their next line until Resume is called.
+// Files already open stay open, and Shutdown still stops a paused run.
+func (p *Processor) Pause() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		p.paused.Store(true)
+		p.ErrorLog.Info("processing paused")
+	}
+}
+
+// Resume continues processing after Pause.
+func (p *Processor) Resume() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resume != nil {
+		p.paused.Store(false)
+		close(p.resume)
+		p.resume = nil
+		p.ErrorLog.Info("processing resumed")
+	}
+}
+
+// Paused reports whether processing is paused.
+func (p *Processor) Paused() bool {
+	return p.paused.Load()
+}
+
+// waitIfPaused blocks while processing is paused or until ctx is done.
+func (p *Processor) waitIfPaused(ctx context.Context) {
+	if !p.paused.Load() {
+		return
+	}
+	p.pauseMu.Lock()
+	resume := p.resume
+	p.pauseMu.Unlock()
+	if resume == nil {
+		return
+	}
+	select {
+	case <-resume:
+	case <-ctx.Done():
+	}
+}
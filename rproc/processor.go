@@ -0,0 +1,1840 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package rproc filters Reddit data dumps in zstd-compressed or plain
+// NDJSON format, writing the records whose fields match a set of values.
+package rproc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/vbauerster/mpb/v8"
+	"golang.org/x/sync/semaphore"
+)
+
+var ErrProcessClosed = errors.New("process: Processor closed")
+
+// ErrDiskFull is the error Serve returns when it stopped because the
+// output disk ran out of space.
+var ErrDiskFull = errors.New("process: output disk is full")
+
+// FilenameField is a pseudo-field that matches against the base name of
+// the input file instead of a JSON field.
+const FilenameField = "__filename"
+
+// zstdOpts allows the large windows used by the Reddit dumps.
+var zstdOpts = []zstd.DOption{
+	zstd.WithDecoderMaxWindow(1 << 32),
+	zstd.WithDecoderMaxMemory(1 << 33),
+	zstd.WithDecoderLowmem(false),
+	zstd.WithDecoderConcurrency(0),
+}
+
+type Processor struct {
+	Threads  int
+	FailFast bool
+	Input    string
+	Files    []string
+	Output   string
+
+	// Values are matched against Fields. Entries starting with "-" exclude
+	// records that match them even if another value matches; a leading
+	// "\-" stands for a literal dash.
+	Fields      []string
+	Values      []string
+	ValuesRegex []*regexp.Regexp
+	FileFilter  *regexp.Regexp
+	Extensions  []string
+	MatchMode   string
+
+	// Matcher, if set, replaces the built-in test of MatchMode for Values.
+	// Field values are still extracted as for MatchMode, and excluded and
+	// blocked values still use the built-in modes. The values it returns
+	// must be listed in Values; matches of other values are dropped and
+	// counted.
+	Matcher Matcher
+
+	// FollowSymlinks descends into symlinked directories while walking
+	// Input. Each directory is visited once, so symlink cycles terminate.
+	FollowSymlinks bool
+
+	// ZstdDict is the path of a zstd dictionary, in the format written by
+	// "zstd --train", used to decompress dictionary-compressed inputs.
+	ZstdDict string
+
+	// ConcurrentDecodeSize decodes zstd files of at least this many bytes,
+	// or of unknown size, with the CPUs divided by Threads as decoder
+	// goroutines and smaller ones with a single goroutine. Zero lets every
+	// decoder use all CPUs.
+	ConcurrentDecodeSize int64
+
+	// ReadRateLimit caps the combined rate at which all workers read their
+	// inputs, in bytes per second as stored, so that a run does not starve
+	// other jobs on shared storage. Zero means unlimited.
+	ReadRateLimit int64
+
+	// ProgressMode "compact" shows a single bar for all input files and the
+	// name of the file last started, instead of one bar per file.
+	ProgressMode string
+
+	// ProgressInterval writes progress.json with the files and input bytes
+	// processed so far and an ETA to Output at this interval. Zero
+	// disables it.
+	ProgressInterval time.Duration
+
+	// RunReport writes report.json to Output once processing finishes,
+	// with the lines scanned and matched and the match rate of the run and
+	// of each input file, flagging files of which nearly all or none of
+	// the lines matched.
+	RunReport bool
+
+	// ExtractMaxValues caps the distinct values collected per field when
+	// MatchMode is "extract", which writes the distinct values of Fields
+	// instead of matching, or "aggregate", which writes how often each
+	// occurs. Zero uses a default of ten million. AggregateBucket, when
+	// positive, aggregates numeric fields into buckets of this width.
+	ExtractMaxValues int
+	AggregateBucket  float64
+
+	// HTMLUnescape decodes HTML entities such as &amp; in field values
+	// before they are matched. Written lines are left unchanged.
+	HTMLUnescape bool
+
+	// EmptyField decides what happens to lines whose fields are missing or
+	// empty: "skip" (or empty) leaves them unmatched, "count" also counts
+	// and logs them at the end, and "match" tests the empty value against
+	// Values like any other, e.g. for a regex such as ^$. Empty fields of a
+	// line where another field has a value are always skipped.
+	EmptyField string
+
+	// FieldAliases lists, by field name, alternate fields whose values are
+	// matched in turn when the field itself is missing or empty, for dumps
+	// from eras that named it differently.
+	FieldAliases map[string][]string
+
+	// RegexCaseInsensitive compiles the values of regex mode as if each
+	// started with (?i).
+	RegexCaseInsensitive bool
+
+	// CombineValues tests all values against a field at once, which is
+	// much faster for long value lists. See valueFilter.combine for how
+	// it changes which value a line is attributed to.
+	CombineValues bool
+
+	// BlockFields, BlockValues and BlockMatchMode describe a second filter
+	// applied to matched records: a record that also matches it is dropped.
+	BlockFields    []string
+	BlockValues    []string
+	BlockMatchMode string
+
+	DedupeField string
+	DedupeStore string
+	ResetDedupe bool
+
+	// DedupeOnClose removes repeated lines from every output file written
+	// during the run once processing finishes, including duplicates that
+	// an earlier run appended.
+	DedupeOnClose bool
+
+	TimeField string
+	TimeStart time.Time
+	TimeEnd   time.Time
+
+	// NumberMin and NumberMax skip records whose NumberField, e.g. score,
+	// falls outside the inclusive range; nil leaves a bound open. Records
+	// must pass the time window, this range and HashSample, in that order,
+	// before their values are matched, and the records each check skips
+	// are counted separately.
+	NumberField string
+	NumberMin   *float64
+	NumberMax   *float64
+
+	// HashSample, when above 1, keeps about one in HashSample records,
+	// chosen by the hash of HashSampleField salted with HashSampleSalt
+	// rather than by position, so a sample is reproducible across runs,
+	// orderings and shards. Records left out are not matched.
+	HashSample      int64
+	HashSampleField string
+	HashSampleSalt  string
+
+	// TimeHistogram counts the matches per hour, day, week, month or year
+	// of TimeField and writes them to time_histogram.csv in Output once
+	// processing finishes. Empty disables it.
+	TimeHistogram string
+
+	AnnotateSource bool
+	CountOnly      bool
+
+	// DebugSample logs the first DebugSample distinct values of each
+	// filtered field found in every input file on a CountOnly run, to show
+	// what the values are compared against.
+	DebugSample int
+
+	Scrub            []*regexp.Regexp
+	ScrubPlaceholder string
+
+	// Compute adds the given fields to every written record, after
+	// scrubbing and before flattening.
+	Compute []ComputedField
+
+	// SortField buffers each output file and writes it sorted by this
+	// field once processing finishes. SortBufferLines caps the lines held
+	// in memory per output file before they are spilled to temporary runs.
+	SortField       string
+	SortBufferLines int
+
+	// OrderedOutput buffers the matched lines of each input file in memory
+	// and writes them once every file before it in discovery order has
+	// finished, so the output is the same on every run.
+	OrderedOutput bool
+
+	// StreamURL, if set, sends every matched line, after deduplication,
+	// scrubbing and annotation, to this URL instead of the output files.
+	// The records are streamed as NDJSON objects holding the input file
+	// name, the matched value and the line in the body of one chunked POST
+	// request that is finished when processing ends. Serve returns an error
+	// if a record could not be sent or the request was not accepted.
+	StreamURL string
+
+	// OutputQueueDepth hands matched lines to a separate writer through a
+	// queue of this many lines. Workers block once it is full, so slow
+	// output slows reading instead of growing memory. Zero writes
+	// synchronously from the workers.
+	OutputQueueDepth int
+
+	// IOThreads limits how many matched lines are written concurrently,
+	// independently of Threads. With an output queue it is the number of
+	// writers draining the queue. Zero means one queue writer, or no limit
+	// when writing directly from the workers.
+	IOThreads int
+
+	// InvalidUTF8 selects what happens to written lines containing invalid
+	// UTF-8: "skip" drops them, "repair" replaces the invalid bytes with
+	// U+FFFD, and anything else writes them unchanged.
+	InvalidUTF8 string
+
+	// OutputLineEnding "crlf" ends written lines with CR LF instead of LF.
+	OutputLineEnding string
+
+	// ValidateOutput parses every line after scrubbing and annotation and
+	// drops the ones that are no longer valid JSON.
+	ValidateOutput bool
+
+	// TrimOutput removes leading and trailing whitespace from written lines
+	// for strict JSON consumers.
+	TrimOutput bool
+
+	// MaxOutputBytes, when positive, caps the bytes of all lines written
+	// in the run. Once the next line would exceed it, the run stops as on
+	// Shutdown, without an error. Which lines make the cut depends on the
+	// timing of the workers and differs between runs.
+	MaxOutputBytes int64
+
+	// FlattenOutput rewrites written records with nested objects and arrays
+	// expanded into top-level keys, such as author_flair_text for
+	// author_flair.text, for columnar stores.
+	FlattenOutput bool
+
+	// OutputLayout "by-value" writes each value's matches to its own
+	// subdirectory of Output instead of adding the value to the file name.
+	OutputLayout string
+
+	// GroupBy names a field whose value is added to the output file name
+	// after the matched value, splitting each value's output further.
+	GroupBy string
+
+	// OutputShards, when positive, spreads output files over this many
+	// subdirectories of Output chosen by a hash of the value, so that
+	// writers for different values can hit different disks.
+	OutputShards int
+
+	// AtomicOutput writes each output file to a temporary file that is
+	// renamed to its final name only once its input has been processed
+	// completely, replacing any existing file. Output of inputs that fail
+	// or are interrupted is discarded. It cannot be combined with
+	// checkpointing.
+	AtomicOutput bool
+
+	// TruncateOutput empties every output file the first time it is opened
+	// in a run, instead of appending to what a previous run left behind.
+	// Output files this run does not write to are left alone. It cannot be
+	// combined with checkpointing, since a resumed run would discard the
+	// output written before the restart.
+	TruncateOutput bool
+
+	// OutputFileMode is the permission used when creating output files,
+	// 0644 if zero. The umask still applies unless ChmodOutput is set.
+	OutputFileMode os.FileMode
+	ChmodOutput    bool
+
+	// OutputChecksums writes a <file>.sha256 sidecar for every output
+	// file written, hashed while the lines are written.
+	OutputChecksums bool
+
+	// OutputCompression "zstd" writes output files as .ndjson.zst, each a
+	// series of zstd frames of about 256 KiB of lines collected in memory
+	// per file. "auto" picks the level of every frame from the CPU time
+	// the run leaves idle and uses the fastest level for files whose lines
+	// hardly compress. Empty or "none" writes plain NDJSON.
+	OutputCompression string
+
+	// RangeOffset and RangeLength restrict every input to the lines that
+	// start within that range of its decompressed bytes, so that several
+	// machines can split one file between them. A line that crosses the
+	// end of the range still belongs to it, and one that crosses its start
+	// to the range before. Zero RangeLength reads to the end. Local inputs
+	// in the zstd seekable format skip to the range without decompressing
+	// what comes before it.
+	RangeOffset int64
+	RangeLength int64
+
+	// SeekableChunkSize splits local inputs in the zstd seekable format at
+	// frame boundaries into chunks of at least this many decompressed
+	// bytes, which are read by separate workers like byte ranges of the
+	// input. Zero reads every input with a single worker.
+	SeekableChunkSize int64
+
+	// OTelEndpoint, if set, is the base URL of an OpenTelemetry collector,
+	// such as http://localhost:4318, that receives spans for the run, input
+	// discovery, each input file and the final output flush, as OTLP over
+	// HTTP. Spans are sent when each Serve ends and when the run ends.
+	OTelEndpoint string
+
+	// CheckpointInterval is the number of lines between saved checkpoints.
+	// Zero disables checkpointing.
+	CheckpointInterval int64
+
+	// OpenInput, if set, opens the input files named in Files instead of
+	// reading them from disk or over HTTP, and returns their size or -1 if
+	// unknown. Together with OnMatch it runs the whole pipeline in memory;
+	// see MemoryInput.
+	OpenInput func(ctx context.Context, name string) (io.ReadCloser, int64, error)
+
+	// OnMatch, if set, is called for every matched line instead of writing
+	// it to the output files. It is called concurrently from the workers,
+	// and line is only valid for the duration of the call.
+	OnMatch func(file, value string, line []byte)
+
+	// Confirm prints the discovered files and the filter summary and waits
+	// for the user to confirm before processing starts. The prompt is
+	// skipped when stdin is not a terminal.
+	Confirm bool
+
+	// ListFields, when positive, samples this many lines of each input
+	// file and prints the fields they contain instead of processing.
+	// ListNested also reports the keys of nested objects as dotted paths.
+	ListFields int
+	ListNested bool
+
+	// FieldLengths, when positive, samples this many lines of each input
+	// file and prints percentiles of the lengths of the filtered fields
+	// and of the lines instead of processing.
+	FieldLengths int
+
+	// BenchLines, when positive, samples this many lines of each input
+	// file and prints the matching throughput of each match mode instead
+	// of processing.
+	BenchLines int
+
+	// Watch keeps running after the initial pass and processes new files
+	// that appear in the Input directory, polling every WatchInterval.
+	// Combined with CheckpointInterval, files finished before a restart
+	// are skipped.
+	Watch         bool
+	WatchInterval time.Duration
+
+	ErrorLog   *slog.Logger
+	inShutdown atomic.Bool
+
+	mu         sync.Mutex
+	onShutdown []func()
+	wg         sync.WaitGroup
+
+	workers     *limiter
+	parallelism int // files read at once, at most one per thread
+	cancelRun   context.CancelCauseFunc
+	watching    bool
+
+	paused  atomic.Bool
+	pauseMu sync.Mutex
+	resume  chan struct{}
+
+	dedupe    *keyStore
+	filter    *valueFilter
+	excludes  []string
+	exclude   *valueFilter
+	block     *valueFilter
+	sorter    *sorter
+	staged    *stagedOutput
+	checksums *checksums
+	ordered   *orderedOutput
+	progress  *progress
+	stream    *stream
+
+	tracer     *tracer
+	traceCtx   context.Context
+	zstdDict   []byte
+	zips       zipArchives
+	extracted  map[string]*valueSet
+	timeHist   *timeHistogram
+	compressed *compressedOutputs
+	queue      chan outputRecord
+	ioSem      *semaphore.Weighted
+
+	outputDirs    sync.Map
+	dedupeOnClose sync.Map // final output paths for DedupeOnClose
+	chmodded      sync.Map
+	truncated     sync.Map // *sync.Once per output path for TruncateOutput
+
+	timeSkipped   atomic.Int64
+	hashSkipped   atomic.Int64
+	numSkipped    atomic.Int64
+	unmatched     atomic.Int64
+	blocked       atomic.Int64
+	excluded      atomic.Int64
+	utf8Skipped   atomic.Int64
+	utf8Repaired  atomic.Int64
+	invalidOutput atomic.Int64
+	scanned       atomic.Int64
+	unknownValues atomic.Int64
+	diskFull      atomic.Bool
+	diskDropped   atomic.Int64
+	outputBytes   atomic.Int64
+	outputFull    atomic.Bool
+	limitDropped  atomic.Int64
+	matchCounts   map[string]*atomic.Int64
+	fileRates     []fileRate // per input file for RunReport, guarded by mu
+}
+
+func (p *Processor) shuttingDown() bool {
+	return p.inShutdown.Load()
+}
+
+func (p *Processor) Shutdown(ctx context.Context) error {
+	p.inShutdown.Store(true)
+
+	p.mu.Lock()
+	for _, f := range p.onShutdown {
+		go f()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+// init builds the value filters and match counters from the configured
+// fields and values. ProcessAndServe runs it before anything else, and
+// Serve when it is called on its own.
+func (p *Processor) init() error {
+	if p.MatchMode != "extract" && p.MatchMode != "aggregate" {
+		p.Values, p.excludes = splitExcludes(p.Values)
+		if len(p.Values) == 0 && len(p.excludes) > 0 {
+			return errors.New("values only holds excluded entries, at least one value to match is needed")
+		}
+	}
+	p.Values = p.uniqueValues()
+	matchCounts := make(map[string]*atomic.Int64, len(p.Values))
+	for _, value := range p.Values {
+		matchCounts[value] = new(atomic.Int64)
+	}
+	// LogSnapshot may read the counts from another goroutine.
+	p.mu.Lock()
+	p.matchCounts = matchCounts
+	p.mu.Unlock()
+
+	filter, err := p.newFilter(p.Fields, p.Values, p.MatchMode)
+	if err != nil {
+		return err
+	}
+	if p.Matcher != nil {
+		filter.matcher = p.customMatcher()
+	}
+	filter.emptyField = p.EmptyField
+	p.filter = filter
+	p.ValuesRegex = filter.regexes
+
+	if len(p.excludes) > 0 {
+		exclude, err := p.newFilter(p.Fields, p.excludes, p.MatchMode)
+		if err != nil {
+			return fmt.Errorf("excluded values: %w", err)
+		}
+		p.exclude = exclude
+	}
+
+	if len(p.BlockValues) > 0 {
+		block, err := p.newFilter(p.BlockFields, p.BlockValues, p.BlockMatchMode)
+		if err != nil {
+			return fmt.Errorf("block filter: %w", err)
+		}
+		p.block = block
+	}
+	return nil
+}
+
+func (p *Processor) ProcessAndServe() error {
+	if p.shuttingDown() {
+		return ErrProcessClosed
+	}
+
+	if p.OTelEndpoint != "" {
+		p.tracer = newTracer(p.OTelEndpoint)
+	}
+	var runSpan *span
+	p.traceCtx, runSpan = p.tracer.start(context.Background(), "run")
+	defer func() {
+		runSpan.finish()
+		p.exportSpans()
+	}()
+
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if len(p.Files) == 0 {
+		if err := checkOverlap(p.Input, p.Output); err != nil {
+			return err
+		}
+	}
+
+	if p.AtomicOutput && p.CheckpointInterval > 0 {
+		return errors.New("atomic output cannot be combined with checkpointing")
+	}
+	if p.TimeHistogram != "" {
+		hist, err := newTimeHistogram(p.TimeHistogram)
+		if err != nil {
+			return err
+		}
+		p.timeHist = hist
+	}
+
+	if p.MaxOutputBytes > 0 && p.AtomicOutput {
+		return errors.New("an output limit cannot be combined with atomic output")
+	}
+	if (p.RangeOffset > 0 || p.RangeLength > 0) && p.CheckpointInterval > 0 {
+		return errors.New("a byte range cannot be combined with checkpointing")
+	}
+	if p.TruncateOutput && p.CheckpointInterval > 0 {
+		return errors.New("truncating output cannot be combined with checkpointing")
+	}
+	if p.OrderedOutput && p.CheckpointInterval > 0 {
+		return errors.New("ordered output cannot be combined with checkpointing")
+	}
+	// Sorted, queued and compressed lines are not yet written when a
+	// checkpoint is saved past them, so a crash would lose them on resume.
+	if p.SortField != "" && p.CheckpointInterval > 0 {
+		return errors.New("sorted output cannot be combined with checkpointing")
+	}
+	if p.OutputQueueDepth > 0 && p.CheckpointInterval > 0 {
+		return errors.New("an output queue cannot be combined with checkpointing")
+	}
+	if p.compressing() && p.CheckpointInterval > 0 {
+		return errors.New("output compression cannot be combined with checkpointing")
+	}
+	if p.compressing() && p.DedupeOnClose {
+		return errors.New("output compression cannot be combined with deduplicating output on close")
+	}
+	// Chunks of one input are read by several workers at once and count
+	// their lines from the start of the chunk.
+	if p.SeekableChunkSize > 0 {
+		switch {
+		case p.CheckpointInterval > 0:
+			return errors.New("splitting seekable inputs cannot be combined with checkpointing")
+		case p.RangeOffset > 0 || p.RangeLength > 0:
+			return errors.New("splitting seekable inputs cannot be combined with a byte range")
+		case p.OrderedOutput:
+			return errors.New("splitting seekable inputs cannot be combined with ordered output")
+		case p.AnnotateSource:
+			return errors.New("splitting seekable inputs cannot be combined with annotating the source line")
+		}
+	}
+
+	if p.Watch && (len(p.Files) > 0 || isURL(p.Input)) {
+		return errors.New("watch needs an input directory")
+	}
+
+	if p.ZstdDict != "" {
+		if err := p.loadZstdDict(); err != nil {
+			return fmt.Errorf("zstd dictionary: %w", err)
+		}
+	}
+
+	defer p.zips.close()
+	_, discoverSpan := p.tracer.start(p.traceCtx, "discover")
+	f, err := p.discover()
+	discoverSpan.set("files", len(f))
+	discoverSpan.fail(err)
+	discoverSpan.finish()
+	if err != nil {
+		return err
+	}
+	if len(f) == 0 && !p.Watch {
+		p.ErrorLog.Warn("no input files found in input folder", "input", p.Input)
+		return nil
+	}
+
+	if p.ListFields > 0 {
+		return p.listFields(f, os.Stdout)
+	}
+	if p.FieldLengths > 0 {
+		return p.fieldLengths(f, os.Stdout)
+	}
+	if p.BenchLines > 0 {
+		return p.benchmark(f, os.Stdout)
+	}
+
+	if len(f) > 0 {
+		p.checkFields(f[0])
+	}
+
+	if p.Confirm && p.TruncateOutput && !isTerminal(os.Stdin) {
+		return errors.New("truncating output needs confirmation; pass -yes when stdin is not a terminal")
+	}
+	if p.Confirm && isTerminal(os.Stdin) {
+		ok, err := p.confirm(f, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			p.ErrorLog.Warn("run cancelled before processing")
+			return nil
+		}
+	}
+
+	if p.DedupeField != "" {
+		store, err := openKeyStore(p.DedupeStore, p.ResetDedupe)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		p.dedupe = store
+		p.ErrorLog.Info("loaded dedupe store", "path", p.DedupeStore, "keys", store.Len())
+	}
+
+	if len(f) > 0 {
+		if err := p.Serve(f); err != nil {
+			return err
+		}
+	}
+	if !p.Watch {
+		return nil
+	}
+	return p.watch(f)
+}
+
+// discover returns the input files to process: the explicit Files, the
+// Input URL, or the matching files found by walking the Input directory.
+// Zip archives are replaced by their matching entries.
+func (p *Processor) discover() ([]string, error) {
+	if len(p.Files) > 0 {
+		var files []string
+		for _, file := range p.Files {
+			if !isZip(file) || isURL(file) {
+				p.ErrorLog.Info("using input file", "path", file)
+				files = append(files, file)
+				continue
+			}
+			entries, err := p.zipInputs(file)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				p.ErrorLog.Info("using input file", "path", entry)
+			}
+			files = append(files, entries...)
+		}
+		return files, nil
+	}
+
+	if isURL(p.Input) {
+		p.ErrorLog.Info("found input url", "url", p.Input)
+		return []string{p.Input}, nil
+	}
+
+	var f []string
+	var skipped []string
+	visited := make(map[string]bool)
+
+	// walk visits the tree at the real path root, reporting paths below
+	// display instead, so files reached through a followed symlink keep
+	// the link in their path.
+	var walk func(root, display string) error
+	walk = func(root, display string) error {
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			real := path
+			if rel, relErr := filepath.Rel(root, path); relErr == nil && display != root {
+				path = filepath.Join(display, rel)
+			}
+			if err != nil {
+				if real == root && display == p.Input {
+					return err
+				}
+				p.ErrorLog.Warn("skipping unreadable path", "path", path, "err", err)
+				skipped = append(skipped, path)
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if p.FollowSymlinks && info.Mode()&os.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(real)
+				if err != nil {
+					p.ErrorLog.Warn("skipping unreadable path", "path", path, "err", err)
+					skipped = append(skipped, path)
+					return nil
+				}
+				if ti, err := os.Stat(target); err == nil && ti.IsDir() {
+					if visited[target] {
+						p.ErrorLog.Debug("skipping already visited symlink target", "path", path, "target", target)
+						return nil
+					}
+					p.ErrorLog.Debug("following symlink", "path", path, "target", target)
+					return walk(target, path)
+				}
+			}
+
+			if info.IsDir() {
+				if p.FollowSymlinks {
+					if visited[real] {
+						return filepath.SkipDir
+					}
+					visited[real] = true
+				}
+				return nil
+			}
+			if !p.hasExtension(info.Name()) {
+				return nil
+			}
+
+			if isZip(info.Name()) {
+				entries, err := p.zipInputs(path)
+				if err != nil {
+					p.ErrorLog.Warn("skipping unreadable path", "path", path, "err", err)
+					skipped = append(skipped, path)
+					return nil
+				}
+				for _, entry := range entries {
+					if !p.watching {
+						p.ErrorLog.Info("found input file", "path", entry)
+					}
+				}
+				f = append(f, entries...)
+				return nil
+			}
+
+			if !p.FileFilter.MatchString(info.Name()) {
+				return nil
+			}
+
+			f = append(f, path)
+			if !p.watching {
+				p.ErrorLog.Info("found input file", "path", path)
+			}
+			return nil
+		})
+	}
+
+	root := p.Input
+	if p.FollowSymlinks {
+		if real, err := filepath.EvalSymlinks(p.Input); err == nil {
+			root = real
+		}
+	}
+	err := walk(root, p.Input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(skipped) > 0 {
+		p.ErrorLog.Warn("skipped unreadable paths during discovery",
+			"count", len(skipped),
+			"paths", skipped,
+		)
+	}
+	return f, nil
+}
+
+// hasExtension reports whether name ends in one of the configured input
+// extensions, ignoring case.
+func (p *Processor) hasExtension(name string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range p.Extensions {
+		if strings.EqualFold(ext, "."+strings.TrimPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFilter returns a value filter with the HTMLUnescape, FieldAliases
+// and CombineValues settings applied.
+func (p *Processor) newFilter(fields, values []string, mode string) (*valueFilter, error) {
+	vf, err := newValueFilter(fields, values, mode)
+	if err != nil {
+		return nil, err
+	}
+	vf.unescape = p.HTMLUnescape
+	vf.aliases = p.FieldAliases
+	if mode == "regex" && p.RegexCaseInsensitive {
+		vf.foldCase = true
+		for i, value := range values {
+			// value compiled on its own, so it still does with the flag.
+			vf.regexes[i] = regexp.MustCompile("(?i)" + value)
+		}
+		vf.matcher = regexMatcher{regexes: vf.regexes, values: values}
+	}
+	if p.CombineValues {
+		if err := vf.combine(); err != nil {
+			return nil, err
+		}
+	}
+	return vf, nil
+}
+
+// uniqueValues returns Values without repeated entries, keeping the first
+// occurrence. Regex and jq values are compared verbatim and everything else
+// case-insensitively, mirroring how the values are matched.
+func (p *Processor) uniqueValues() []string {
+	seen := make(map[string]struct{}, len(p.Values))
+	values := make([]string, 0, len(p.Values))
+	for _, value := range p.Values {
+		key := value
+		if (p.MatchMode != "regex" || p.RegexCaseInsensitive) && p.MatchMode != "jq" {
+			key = strings.ToLower(value)
+		}
+		if _, ok := seen[key]; ok {
+			p.ErrorLog.Warn("ignoring duplicate filter value", "value", value)
+			continue
+		}
+		seen[key] = struct{}{}
+		values = append(values, value)
+	}
+	return values
+}
+
+type contextKey struct {
+	name string
+}
+
+var ServerContextKey = &contextKey{"process-server"}
+
+func (p *Processor) Serve(f []string) error {
+	start := time.Now()
+	startUser, startSystem, _ := cpuTime()
+	if p.filter == nil {
+		if err := p.init(); err != nil {
+			return err
+		}
+	}
+	if p.RangeOffset > 0 || p.RangeLength > 0 {
+		if err := p.checkLineDelimited(f); err != nil {
+			return err
+		}
+	}
+
+	threads := p.Threads
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+	workers := newLimiter(threads)
+	p.mu.Lock()
+	p.workers = workers
+	p.mu.Unlock()
+	tasks := p.splitInputs(f)
+	// Decoders may use the CPUs of workers that have no file to read.
+	p.parallelism = max(min(threads, len(tasks)), 1)
+	p.logParallelism(f, len(tasks), threads)
+	baseCtx, serveSpan := p.tracer.start(p.traceContext(), "serve")
+	serveSpan.set("files", len(f))
+	defer func() {
+		serveSpan.finish()
+		p.exportSpans()
+	}()
+	ctx, cancel := context.WithCancelCause(context.WithValue(baseCtx, ServerContextKey, p))
+	defer cancel(nil)
+
+	p.mu.Lock()
+	p.onShutdown = append(p.onShutdown, func() { cancel(ErrProcessClosed) })
+	p.mu.Unlock()
+	if p.shuttingDown() {
+		cancel(ErrProcessClosed)
+	}
+	p.cancelRun = cancel
+
+	if p.compressing() {
+		compressed, err := newCompressedOutputs(p.OutputCompression == "auto")
+		if err != nil {
+			return fmt.Errorf("output compression: %w", err)
+		}
+		p.compressed = compressed
+	}
+	if p.SortField != "" {
+		open := p.openOutput
+		if p.compressed != nil {
+			open = p.openFramed
+		}
+		p.sorter = newSorter(p.SortBufferLines, p.lineEnding(), open)
+	}
+	if p.AtomicOutput {
+		p.staged = newStagedOutput()
+	}
+	if p.OrderedOutput {
+		p.ordered = newOrderedOutput(f)
+	}
+	if p.OutputChecksums {
+		p.checksums = newChecksums()
+	}
+	if p.MatchMode == "extract" || p.MatchMode == "aggregate" {
+		p.extracted = p.newExtractSets()
+	}
+
+	if p.StreamURL != "" {
+		s, err := openStream(http.DefaultClient, p.StreamURL, p.ErrorLog)
+		if err != nil {
+			return fmt.Errorf("stream: %w", err)
+		}
+		p.stream = s
+	}
+
+	var writers sync.WaitGroup
+	if p.OutputQueueDepth > 0 {
+		p.queue = make(chan outputRecord, p.OutputQueueDepth)
+		for range max(p.IOThreads, 1) {
+			writers.Go(func() {
+				for r := range p.queue {
+					p.write(r.file, r.value, r.lineNo, r.line)
+				}
+			})
+		}
+	} else if p.IOThreads > 0 {
+		p.ioSem = semaphore.NewWeighted(int64(p.IOThreads))
+	}
+
+	var tracking sync.WaitGroup
+	stopProgress := make(chan struct{})
+	if p.ProgressInterval > 0 {
+		p.progress = newProgress(f, p.inputSize)
+		tracking.Go(func() { p.trackProgress(stopProgress) })
+	}
+
+	var readLimit *readLimiter
+	if p.ReadRateLimit > 0 {
+		readLimit = newReadLimiter(p.ReadRateLimit)
+	}
+
+	barz := mpb.New(mpb.WithWidth(64))
+	var compact *compactBar
+	if p.ProgressMode == "compact" {
+		var total int64
+		for _, file := range f {
+			if isURL(file) {
+				continue
+			}
+			if size, err := p.inputSize(file); err == nil {
+				total += size
+			}
+		}
+		compact = newCompactBar(barz, len(f), total)
+	}
+	if p.staged != nil {
+		for _, task := range tasks {
+			p.staged.expectParts(task.file, task.parts)
+		}
+	}
+
+	// Worker IDs are reused as workers end, so the statistics grow only
+	// when the thread count is raised.
+	var stats []*workerStats
+
+	for i, task := range tasks {
+		file := task.file
+		id, err := workers.acquire(ctx)
+		if err != nil {
+			p.ErrorLog.Warn("stopped launching workers",
+				"skipped", len(tasks)-i,
+				"err", context.Cause(ctx),
+			)
+			break
+		}
+		for len(stats) <= id {
+			stats = append(stats, new(workerStats))
+		}
+		ws := stats[id]
+
+		p.wg.Go(func() {
+			ws.files++
+			ctx, fileSpan := p.tracer.start(ctx, "process file")
+			fileSpan.set("file", file)
+			if task.parts > 0 {
+				fileSpan.set("chunk", task.chunk)
+			}
+
+			defer func() {
+				workers.release(id)
+				if task.finish() && p.progress != nil {
+					p.progress.filesDone.Add(1)
+				}
+				defer fileSpan.finish()
+				if pv := recover(); pv != nil {
+					fileSpan.fail(pv)
+					p.ErrorLog.Error("panic recovered in worker", "panic", pv)
+					if p.FailFast {
+						err, ok := pv.(error)
+						if !ok {
+							err = fmt.Errorf("%v", pv)
+						}
+						cancel(fmt.Errorf("processing %s: %w", file, err))
+					}
+				}
+			}()
+			if p.ordered != nil {
+				defer p.flushOrdered(file, false)
+			}
+
+			input, totalBytes, err := p.openInput(ctx, file)
+			if err != nil {
+				p.ErrorLog.Error("failed to open input", "path", file, "err", err)
+				panic(err)
+			}
+			defer input.Close()
+			fileSpan.set("size", totalBytes)
+
+			var resume checkpoint
+			if p.CheckpointInterval > 0 {
+				resume, err = p.loadCheckpoint(file)
+				if err != nil {
+					p.ErrorLog.Error("failed to load checkpoint", "path", file, "err", err)
+					panic(err)
+				}
+				if resume.Complete {
+					p.ErrorLog.Info("skipping completed file", "path", file)
+					return
+				}
+				if resume.Line > 0 {
+					p.ErrorLog.Info("resuming file from checkpoint",
+						"path", file,
+						"line", resume.Line,
+						"offset", resume.Offset,
+					)
+				}
+			}
+			var seekedTo int64
+			seeked := false
+			if resume.Offset > 0 && isZstd(file) {
+				seekedTo, seeked = p.seekResume(file, input, totalBytes, resume.Offset)
+			} else if task.offset > 0 && isZstd(file) {
+				seekedTo, _ = p.seekResume(file, input, totalBytes, task.offset-1)
+			}
+
+			var source io.Reader = input
+			if readLimit != nil {
+				source = &throttledReader{r: source, l: readLimit}
+			}
+			if p.progress != nil {
+				if isURL(file) && totalBytes > 0 {
+					p.progress.bytesTotal.Add(totalBytes)
+				}
+				source = &countingReader{r: source, n: &p.progress.bytesDone}
+			}
+			reader, release, err := p.decode(file, source, totalBytes)
+			if err != nil {
+				p.ErrorLog.Error("failed to create reader", "path", file, "err", err)
+				panic(err)
+			}
+			defer release()
+			if seeked {
+				if _, err := io.CopyN(io.Discard, reader, resume.Offset-seekedTo); err != nil {
+					p.ErrorLog.Error("failed to read input", "path", file, "err", err)
+					panic(err)
+				}
+			}
+			var rangePos int64
+			if task.offset > 0 {
+				reader, rangePos, err = alignToRange(reader, seekedTo, task.offset)
+				if err != nil {
+					p.ErrorLog.Error("failed to read input", "path", file, "err", err)
+					panic(err)
+				}
+			}
+			rangeEnd := task.offset + task.length
+			if totalBytes < 0 {
+				totalBytes = 0
+			}
+
+			scanner, isArray := newRecordScanner(reader)
+			if isArray {
+				if task.offset > 0 || task.length > 0 {
+					err := errors.New("a byte range needs line-delimited input, not a JSON array")
+					p.ErrorLog.Error("failed to read input", "path", file, "err", err)
+					panic(err)
+				}
+				p.ErrorLog.Info("reading records from a top-level JSON array", "path", file)
+			}
+
+			var bar *mpb.Bar
+			if compact != nil {
+				compact.start(file, totalBytes)
+				bar = compact.bar
+			} else {
+				barTotal := totalBytes
+				if task.parts > 0 {
+					barTotal = task.size
+				}
+				bar = newFileBar(barz, task.name(), barTotal)
+			}
+
+			var sample *valueSample
+			if p.CountOnly && p.DebugSample > 0 {
+				sample = newValueSample(p.filter.fields, p.DebugSample)
+				defer p.logSample(file, sample)
+			}
+
+			sampled := false
+			var lineNo, offset int64
+			if seeked {
+				lineNo, offset = resume.Line, resume.Offset
+			}
+			var fileLines, fileMatched int64
+			defer func() {
+				p.scanned.Add(fileLines % scannedBatch)
+				fileSpan.set("lines", fileLines)
+				fileSpan.set("matches", fileMatched)
+			}()
+			for scanner.Scan() {
+				p.waitIfPaused(ctx)
+				if p.shuttingDown() || ctx.Err() != nil {
+					// After the disk filled up, lines before lineNo may have
+					// been dropped, so the checkpoint must not move past them.
+					if p.CheckpointInterval > 0 && lineNo > resume.Line && !p.diskFull.Load() {
+						p.saveCheckpoint(file, checkpoint{Line: lineNo, Offset: offset})
+					}
+					p.ErrorLog.WarnContext(ctx,
+						"skipping further processing of file",
+						"path", file,
+					)
+					return
+				}
+				if p.CheckpointInterval > 0 && lineNo > resume.Line && lineNo%p.CheckpointInterval == 0 {
+					p.saveCheckpoint(file, checkpoint{Line: lineNo, Offset: offset})
+				}
+
+				line := scanner.Bytes()
+				size := int64(scanner.Size())
+				if task.length > 0 {
+					if rangePos >= rangeEnd {
+						break
+					}
+					rangePos += size
+				}
+				lineNo++
+				if !isArray {
+					// Elements of an array are counted but have no offset
+					// to seek to, so their checkpoints resume by line.
+					offset += size
+				}
+				if lineNo <= resume.Line {
+					continue
+				}
+				ws.lines++
+				if fileLines++; fileLines%scannedBatch == 0 {
+					p.scanned.Add(scannedBatch)
+				}
+				ws.bytes += size
+				if len(line) == 0 {
+					continue
+				}
+				if !sampled {
+					p.checkSchema(file, line)
+					sampled = true
+				}
+
+				if !p.inTimeWindow(line) {
+					p.timeSkipped.Add(1)
+					bar.IncrBy(512)
+					continue
+				}
+
+				if !p.inNumberRange(line) {
+					p.numSkipped.Add(1)
+					bar.IncrBy(512)
+					continue
+				}
+
+				if !p.inHashSample(line) {
+					p.hashSkipped.Add(1)
+					bar.IncrBy(512)
+					continue
+				}
+
+				if p.extracted != nil {
+					p.extract(file, line)
+					bar.IncrBy(512)
+					continue
+				}
+
+				if sample != nil {
+					sample.add(p.filter, file, line)
+				}
+				if val, ok := p.filter.match(file, line); ok {
+					if p.exclude != nil {
+						if _, ok := p.exclude.match(file, line); ok {
+							p.excluded.Add(1)
+							bar.IncrBy(512)
+							continue
+						}
+					}
+					if p.block != nil {
+						if _, ok := p.block.match(file, line); ok {
+							p.blocked.Add(1)
+							bar.IncrBy(512)
+							continue
+						}
+					}
+					p.matchCounts[val].Add(1)
+					if p.timeHist != nil {
+						p.timeHist.add(line, p.TimeField)
+					}
+					fileMatched++
+					if p.OnMatch != nil {
+						p.OnMatch(file, val, line)
+					} else if !p.CountOnly {
+						p.emit(file, val, lineNo, string(line))
+					}
+				} else {
+					p.unmatched.Add(1)
+				}
+				bar.IncrBy(512)
+			}
+			if err := scanner.Err(); err != nil {
+				p.ErrorLog.Error("failed to read input", "path", file, "err", err)
+				panic(err)
+			}
+			p.logMatchRate(file, fileLines, fileMatched)
+			if p.CheckpointInterval > 0 && !p.diskFull.Load() {
+				p.saveCheckpoint(file, checkpoint{Line: lineNo, Offset: offset, Complete: true})
+			}
+			if p.staged != nil {
+				p.staged.markComplete(file)
+			}
+		})
+
+	}
+
+	p.wg.Wait()
+	if compact != nil {
+		compact.done()
+	}
+	_, flushSpan := p.tracer.start(ctx, "flush output")
+	if p.ordered != nil {
+		p.flushOrdered("", true)
+	}
+	if p.queue != nil {
+		close(p.queue)
+		writers.Wait()
+		p.queue = nil
+	}
+	var streamErr error
+	if p.stream != nil {
+		streamErr = p.stream.close()
+	}
+	if p.sorter != nil {
+		if err := p.sorter.flush(); err != nil {
+			p.ErrorLog.Error("failed to write sorted output", "err", err)
+		}
+	}
+	if p.compressed != nil {
+		p.flushCompressed()
+	}
+	if p.staged != nil {
+		p.commitStaged()
+	}
+	if p.DedupeOnClose {
+		p.dedupeOutputs()
+	}
+	if p.checksums != nil {
+		p.writeChecksums()
+	}
+	if p.extracted != nil {
+		p.writeExtracted()
+	}
+	if p.timeHist != nil {
+		p.writeTimeHistogram()
+	}
+	flushSpan.finish()
+	close(stopProgress)
+	tracking.Wait()
+	p.logWorkerStats(stats, threads)
+	p.logRunTime(start, startUser, startSystem)
+	if p.CountOnly {
+		p.printCounts(os.Stdout)
+	}
+	if rate := matchRate(p.scanned.Load(), p.totalMatches()); rate != nil && p.extracted == nil {
+		p.ErrorLog.Info("match rate",
+			"lines", p.scanned.Load(),
+			"matched", p.totalMatches(),
+			"rate", formatRate(*rate),
+		)
+	}
+	if p.RunReport {
+		p.writeReport(start)
+	}
+	if !p.TimeStart.IsZero() || !p.TimeEnd.IsZero() {
+		p.ErrorLog.Info("skipped records outside time window", "count", p.timeSkipped.Load())
+	}
+	if p.NumberMin != nil || p.NumberMax != nil {
+		p.ErrorLog.Info("skipped records outside number range",
+			"field", p.NumberField,
+			"count", p.numSkipped.Load(),
+		)
+	}
+	if p.HashSample > 1 {
+		p.ErrorLog.Info("skipped records outside hash sample", "count", p.hashSkipped.Load())
+	}
+	if p.prefiltered() {
+		p.ErrorLog.Info("skipped records without a matching value", "count", p.unmatched.Load())
+	}
+	if p.exclude != nil {
+		p.ErrorLog.Info("skipped matched records with an excluded value", "count", p.excluded.Load())
+	}
+	if p.block != nil {
+		p.ErrorLog.Info("skipped matched records on the block list", "count", p.blocked.Load())
+	}
+	if p.EmptyField == "count" {
+		p.ErrorLog.Info("skipped lines with empty fields", "count", p.filter.empty.Load())
+	}
+	if n := p.unknownValues.Load(); n > 0 {
+		p.ErrorLog.Warn("dropped matches of values not in values", "count", n)
+	}
+	if n := p.utf8Skipped.Load(); n > 0 {
+		p.ErrorLog.Warn("skipped lines with invalid UTF-8", "count", n)
+	}
+	if n := p.utf8Repaired.Load(); n > 0 {
+		p.ErrorLog.Warn("repaired lines with invalid UTF-8", "count", n)
+	}
+	if n := p.invalidOutput.Load(); n > 0 {
+		p.ErrorLog.Warn("dropped output lines that were not valid JSON", "count", n)
+	}
+	if n := p.diskDropped.Load(); n > 0 {
+		p.ErrorLog.Error("dropped output lines because the output disk is full", "count", n)
+	}
+	if n := p.limitDropped.Load(); n > 0 {
+		p.ErrorLog.Info("dropped matched lines over the output limit", "count", n)
+	}
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, errOutputLimit) {
+		return err
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	if p.shuttingDown() {
+		return ErrProcessClosed
+	}
+
+	return nil
+}
+
+// workerStats accumulates the work done by one worker slot. A slot is
+// only ever held by one goroutine at a time, so no locking is needed.
+type workerStats struct {
+	files int
+	lines int64
+	bytes int64 // decompressed bytes scanned
+}
+
+// logWorkerStats logs the work of each worker slot and how many of the
+// threads were used. Slots are numbered from zero, so the number of slots
+// is the most workers that ran at once.
+func (p *Processor) logWorkerStats(stats []*workerStats, threads int) {
+	for id, ws := range stats {
+		p.ErrorLog.Info("worker stats",
+			"worker", id,
+			"files", ws.files,
+			"lines", ws.lines,
+			"bytes", ws.bytes,
+		)
+	}
+	p.ErrorLog.Info("parallelism", "threads", threads, "workers", len(stats))
+}
+
+// logRunTime logs the wall-clock time since start and, where the platform
+// reports it, the CPU time consumed since the start readings.
+func (p *Processor) logRunTime(start time.Time, startUser, startSystem time.Duration) {
+	wall := time.Since(start)
+	user, system, ok := cpuTime()
+	if !ok {
+		p.ErrorLog.Info("run time", "wall", wall.Round(time.Millisecond))
+		return
+	}
+	user -= startUser
+	system -= startSystem
+	p.ErrorLog.Info("run time",
+		"wall", wall.Round(time.Millisecond),
+		"cpu", (user + system).Round(time.Millisecond),
+		"user", user.Round(time.Millisecond),
+		"system", system.Round(time.Millisecond),
+		"cpu_per_wall", fmt.Sprintf("%.2f", (user+system).Seconds()/wall.Seconds()),
+	)
+}
+
+// printCounts writes a table of the number of matches per value.
+func (p *Processor) printCounts(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VALUE\tMATCHES")
+	for _, value := range p.Values {
+		fmt.Fprintf(tw, "%s\t%d\n", value, p.matchCounts[value].Load())
+	}
+	tw.Flush()
+}
+
+var valueTypeNames = map[string]jsoniter.ValueType{
+	"string": jsoniter.StringValue,
+	"number": jsoniter.NumberValue,
+	"bool":   jsoniter.BoolValue,
+	"null":   jsoniter.NilValue,
+	"object": jsoniter.ObjectValue,
+	"array":  jsoniter.ArrayValue,
+}
+
+// valueTypeName returns the type mode name of a JSON value type, or an
+// empty string when the field is missing.
+func valueTypeName(t jsoniter.ValueType) string {
+	for name, vt := range valueTypeNames {
+		if vt == t {
+			return name
+		}
+	}
+	return ""
+}
+
+// annotate injects the source file and line number into a JSON object
+// line. Lines that are not objects are returned unchanged.
+func annotate(line, inputPath string, lineNo int64) string {
+	trimmed := strings.TrimRight(line, " \t\r")
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return line
+	}
+	name, _ := jsoniter.MarshalToString(inputName(inputPath))
+	body := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+	fields := fmt.Sprintf(`"_source_file":%s,"_source_line":%d`, name, lineNo)
+	if body == "" {
+		return "{" + fields + "}"
+	}
+	return trimmed[:len(trimmed)-1] + "," + fields + "}"
+}
+
+type outputRecord struct {
+	file   string
+	value  string
+	lineNo int64
+	line   string
+}
+
+// emit passes a matched line to the output queue, blocking while it is
+// full, or writes it directly when no queue is configured, waiting for
+// an I/O slot if IOThreads is set.
+func (p *Processor) emit(file, value string, lineNo int64, line string) {
+	if p.ordered != nil {
+		p.ordered.add(outputRecord{file: file, value: value, lineNo: lineNo, line: line})
+		return
+	}
+	if p.queue != nil {
+		p.queue <- outputRecord{file: file, value: value, lineNo: lineNo, line: line}
+		return
+	}
+	if p.ioSem != nil {
+		_ = p.ioSem.Acquire(context.Background(), 1)
+		defer p.ioSem.Release(1)
+	}
+	p.write(file, value, lineNo, line)
+}
+
+// openOutput opens an output file for appending, creating it with
+// OutputFileMode. With TruncateOutput, the first open of a path in a run
+// empties it, and concurrent opens wait for that. With ChmodOutput the
+// mode is also applied explicitly, once per file, so that it is not
+// narrowed by the umask. With OutputChecksums, writes also update the
+// file's running hash. A write that fails part way, such as on a full
+// disk, is cut off again; see writeAll.
+func (p *Processor) openOutput(path string) (io.WriteCloser, error) {
+	mode := p.OutputFileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	var (
+		f      *os.File
+		err    error
+		opened bool
+	)
+	if p.TruncateOutput {
+		once, _ := p.truncated.LoadOrStore(path, new(sync.Once))
+		once.(*sync.Once).Do(func() {
+			f, err = os.OpenFile(path, flag|os.O_TRUNC, mode)
+			opened = true
+		})
+	}
+	if !opened {
+		f, err = os.OpenFile(path, flag, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.ChmodOutput {
+		if _, done := p.chmodded.LoadOrStore(path, struct{}{}); !done {
+			if err := f.Chmod(mode); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	if p.checksums != nil {
+		fh, err := p.checksums.get(path)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &hashedFile{File: f, fh: fh}, nil
+	}
+	return appendFile{f}, nil
+}
+
+// appendFile is an output file opened for appending whose failed writes
+// leave no partial line behind.
+type appendFile struct {
+	*os.File
+}
+
+func (f appendFile) Write(b []byte) (int, error) {
+	return writeAll(f.File, b)
+}
+
+func (f appendFile) WriteString(s string) (int, error) {
+	return writeAll(f.File, []byte(s))
+}
+
+// writeAll appends b to f. If the write fails after writing part of b, as
+// when the disk fills up, f is truncated back to its size before the write
+// so that it does not end in half a line, unless something else has been
+// appended since. It returns the number of bytes of b left in f.
+func writeAll(f *os.File, b []byte) (int, error) {
+	n, err := f.Write(b)
+	if err == nil || n == 0 {
+		return n, err
+	}
+	end, serr := f.Seek(0, io.SeekCurrent)
+	if serr != nil {
+		return n, err
+	}
+	if info, serr := f.Stat(); serr != nil || info.Size() != end {
+		return n, err
+	}
+	if f.Truncate(end-int64(n)) != nil {
+		return n, err
+	}
+	return 0, err
+}
+
+// lineEnding returns the terminator appended to every written line.
+func (p *Processor) lineEnding() string {
+	if p.OutputLineEnding == "crlf" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// outputPath returns the output file for matches of value in inputPath,
+// creating its shard and per-value directories first if needed. A non-empty
+// group is appended to the file name.
+func (p *Processor) outputPath(inputPath, value, group string) (string, error) {
+	base := strings.TrimSuffix(inputName(inputPath), filepath.Ext(inputName(inputPath)))
+	dir := p.Output
+	if p.OutputShards > 0 {
+		dir = filepath.Join(dir, shardDir(value, p.OutputShards))
+	}
+	// Values come from the input, so they must not be able to add path
+	// elements or climb out of Output.
+	name := base + "_" + safeName(value)
+	if p.OutputLayout == "by-value" {
+		dir = filepath.Join(dir, safeName(value))
+		name = base
+	}
+	if group != "" {
+		name += "_" + group
+	}
+	name += ".ndjson"
+	if p.compressed != nil {
+		name += ".zst"
+	}
+
+	if dir != p.Output {
+		if _, ok := p.outputDirs.Load(dir); !ok {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return "", err
+			}
+			p.outputDirs.Store(dir, struct{}{})
+		}
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// groupName turns a GroupBy field value into a part of a file name.
+// Records without the field are grouped under _none.
+func groupName(value string) string {
+	if value == "" {
+		return "_none"
+	}
+	return safeName(value)
+}
+
+// safeName turns a value into a single file or directory name. Path
+// separators and characters not allowed in Windows file names become
+// underscores, and so do the dots of "." and "..".
+func safeName(value string) string {
+	if value == "." || value == ".." {
+		return strings.Repeat("_", len(value))
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// shardDir returns the name of the shard directory, out of shards, that
+// holds the output files of value.
+func shardDir(value string, shards int) string {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return fmt.Sprintf("shard-%02d", h.Sum32()%uint32(shards))
+}
+
+func (p *Processor) write(inputPath, value string, lineNo int64, line string) {
+	var group string
+	if p.GroupBy != "" {
+		group = groupName(jsoniter.Get([]byte(line), p.GroupBy).ToString())
+	}
+	outFileName, err := p.outputPath(inputPath, value, group)
+	if err != nil {
+		if p.stopIfDiskFull(err) {
+			return
+		}
+		p.ErrorLog.Warn("failed to create output directory",
+			"value", value,
+			"err", err,
+		)
+		return
+	}
+
+	// The scanner drops the CR of a CRLF line ending, but a line that ended
+	// in another CR still has one, which would double up with crlf.
+	line = strings.TrimSuffix(line, "\r")
+	if p.TrimOutput {
+		line = strings.TrimSpace(line)
+	}
+
+	if (p.InvalidUTF8 == "skip" || p.InvalidUTF8 == "repair") && !utf8.ValidString(line) {
+		if p.InvalidUTF8 == "skip" {
+			p.utf8Skipped.Add(1)
+			return
+		}
+		line = strings.ToValidUTF8(line, "\uFFFD")
+		p.utf8Repaired.Add(1)
+	}
+
+	// written is set once the line has been handed to the output, and only
+	// then is its dedupe key kept, so that a line dropped on the way is not
+	// skipped by later runs.
+	var written bool
+	if p.dedupe != nil {
+		if key := jsoniter.Get([]byte(line), p.DedupeField).ToString(); key != "" {
+			if !p.dedupe.Claim(key) {
+				return
+			}
+			defer func() { p.settleDedupeKey(key, written) }()
+		}
+	}
+
+	var key sortKey
+	if p.sorter != nil {
+		key = newSortKey([]byte(line), p.SortField)
+	}
+
+	for _, re := range p.Scrub {
+		line = re.ReplaceAllLiteralString(line, p.ScrubPlaceholder)
+	}
+	if len(p.Compute) > 0 {
+		line = addComputed(line, p.Compute)
+	}
+	if p.FlattenOutput {
+		line = flatten(line)
+	}
+	if p.AnnotateSource {
+		line = annotate(line, inputPath, lineNo)
+	}
+	if p.ValidateOutput && !jsoniter.Valid([]byte(line)) {
+		p.invalidOutput.Add(1)
+		p.ErrorLog.Warn("dropping output line that is not valid JSON",
+			"path", inputPath,
+			"line", lineNo,
+		)
+		return
+	}
+	if !p.reserveOutput(len(line) + len(p.lineEnding())) {
+		return
+	}
+
+	if p.stream != nil {
+		written = p.stream.send(inputName(inputPath), value, line)
+		return
+	}
+
+	if p.DedupeOnClose {
+		p.dedupeOnClose.Store(outFileName, struct{}{})
+	}
+	if p.staged != nil {
+		outFileName, err = p.staged.stage(inputPath, outFileName)
+		if err != nil {
+			if p.stopIfDiskFull(err) {
+				return
+			}
+			p.ErrorLog.Warn("failed to stage output file",
+				"path", outFileName,
+				"err", err,
+			)
+			return
+		}
+	}
+
+	if p.sorter != nil {
+		if err := p.sorter.add(outFileName, key, line); err != nil {
+			if p.stopIfDiskFull(err) {
+				return
+			}
+			p.ErrorLog.Warn("failed to spill sorted output",
+				"path", outFileName,
+				"err", err,
+			)
+			return
+		}
+		written = true
+		return
+	}
+
+	if p.compressed != nil {
+		if lines, err := p.writeCompressed(outFileName, line+p.lineEnding()); err != nil {
+			p.frameFailed(outFileName, lines, err)
+			return
+		}
+		written = true
+		return
+	}
+
+	outFile, err := p.openOutput(outFileName)
+	if err != nil {
+		if p.stopIfDiskFull(err) {
+			return
+		}
+		p.ErrorLog.Warn("failed to open output file",
+			"path", outFileName,
+			"err", err,
+		)
+		return
+	}
+	defer outFile.Close()
+
+	if _, err := io.WriteString(outFile, line+p.lineEnding()); err != nil {
+		if p.stopIfDiskFull(err) {
+			return
+		}
+		p.ErrorLog.Warn("failed to write to output file",
+			"path", outFileName,
+			"err", err,
+		)
+		return
+	}
+	written = true
+}
+
+// settleDedupeKey records a claimed dedupe key once its line has been
+// written, or frees it for a later line if it was dropped.
+func (p *Processor) settleDedupeKey(key string, written bool) {
+	if !written {
+		p.dedupe.Release(key)
+		return
+	}
+	if err := p.dedupe.Commit(key); err != nil {
+		p.ErrorLog.Warn("failed to record dedupe key",
+			"path", p.DedupeStore,
+			"err", err,
+		)
+	}
+}
+
+// stopIfDiskFull reports whether err means the output disk is full. The
+// first time, it stops the run with ErrDiskFull, so that workers stop
+// reading and whatever is buffered is still written out. Lines that fail
+// to be written after that are counted rather than logged one by one.
+func (p *Processor) stopIfDiskFull(err error) bool {
+	if !isDiskFull(err) {
+		return false
+	}
+	p.diskDropped.Add(1)
+	if p.diskFull.CompareAndSwap(false, true) {
+		p.ErrorLog.Error("output disk is full, stopping", "err", err)
+		p.cancelRun(ErrDiskFull)
+	}
+	return true
+}
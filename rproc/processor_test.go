@@ -0,0 +1,316 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// TestServeWithoutProcessAndServe checks that Serve builds the filters
+// itself when it is called directly instead of through ProcessAndServe.
+func TestServeWithoutProcessAndServe(t *testing.T) {
+	data := []byte(`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+		`{"id":"t1_b","subreddit":"rust"}` + "\n" +
+		`{"id":"t1_c","subreddit":"golang"}` + "\n")
+
+	var matched atomic.Int64
+	p := &Processor{
+		Output:     t.TempDir(),
+		Threads:    2,
+		Fields:     []string{"subreddit"},
+		Values:     []string{"golang"},
+		FileFilter: regexp.MustCompile(".*"),
+		Extensions: []string{".ndjson"},
+		MatchMode:  "exact",
+		TimeField:  "created_utc",
+		OpenInput:  MemoryInput(map[string][]byte{"RC_serve.ndjson": data}),
+		OnMatch: func(_, _ string, _ []byte) {
+			matched.Add(1)
+		},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.Serve([]string{"RC_serve.ndjson"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := matched.Load(); got != 2 {
+		t.Errorf("matched %d lines, want 2", got)
+	}
+	if got := p.matchCounts["golang"].Load(); got != 2 {
+		t.Errorf("match count for golang is %d, want 2", got)
+	}
+}
+
+// TestFilesSkipOverlapCheck checks that an Input overlapping Output does
+// not fail a run whose input is given as Files.
+func TestFilesSkipOverlapCheck(t *testing.T) {
+	out := t.TempDir()
+	p := &Processor{
+		Input:      out,
+		Output:     out,
+		Files:      []string{"RC_files.ndjson"},
+		Threads:    1,
+		Fields:     []string{"subreddit"},
+		Values:     []string{"golang"},
+		FileFilter: regexp.MustCompile(".*"),
+		Extensions: []string{".ndjson"},
+		MatchMode:  "exact",
+		TimeField:  "created_utc",
+		OpenInput: MemoryInput(map[string][]byte{
+			"RC_files.ndjson": []byte(`{"subreddit":"golang"}` + "\n"),
+		}),
+		OnMatch:  func(_, _ string, _ []byte) {},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOutputPath checks where the flat and by-value layouts put output
+// files, and that values cannot add path elements.
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		layout, value, group string
+		want                 string
+	}{
+		{"flat", "golang", "", "RC_2024-01_golang.ndjson"},
+		{"flat", "golang", "t5_x", "RC_2024-01_golang_t5_x.ndjson"},
+		{"flat", "../up", "", "RC_2024-01_.._up.ndjson"},
+		{"by-value", "golang", "", "golang/RC_2024-01.ndjson"},
+		{"by-value", "golang", "t5_x", "golang/RC_2024-01_t5_x.ndjson"},
+		{"by-value", "a/b", "", "a_b/RC_2024-01.ndjson"},
+		{"by-value", "..", "", "__/RC_2024-01.ndjson"},
+		{"by-value", ".", "", "_/RC_2024-01.ndjson"},
+		{"by-value", `c:\x?`, "", "c__x_/RC_2024-01.ndjson"},
+	}
+	for _, tt := range tests {
+		out := t.TempDir()
+		p := &Processor{Output: out, OutputLayout: tt.layout}
+		got, err := p.outputPath("/dumps/RC_2024-01.zst", tt.value, tt.group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(out, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("%s layout, value %q: path %s, want %s", tt.layout, tt.value, got, want)
+		}
+		if info, err := os.Stat(filepath.Dir(got)); err != nil || !info.IsDir() {
+			t.Errorf("%s layout, value %q: directory of %s not created", tt.layout, tt.value, got)
+		}
+	}
+}
+
+// TestOutputLayoutByValue checks that a run with the by-value layout
+// writes each value's matches to its own directory.
+func TestOutputLayoutByValue(t *testing.T) {
+	out := t.TempDir()
+	p := &Processor{
+		Output:       out,
+		Files:        []string{"RC_layout.ndjson"},
+		Threads:      1,
+		Fields:       []string{"subreddit"},
+		Values:       []string{"golang", "rust"},
+		FileFilter:   regexp.MustCompile(".*"),
+		Extensions:   []string{".ndjson"},
+		MatchMode:    "exact",
+		TimeField:    "created_utc",
+		OutputLayout: "by-value",
+		OpenInput: MemoryInput(map[string][]byte{"RC_layout.ndjson": []byte(
+			`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+				`{"id":"t1_b","subreddit":"rust"}` + "\n" +
+				`{"id":"t1_c","subreddit":"golang"}` + "\n")}),
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+	for value, want := range map[string]int{"golang": 2, "rust": 1} {
+		if ids := outputIDs(t, filepath.Join(out, value)); len(ids) != want {
+			t.Errorf("%s directory holds %v, want %d records", value, ids, want)
+		}
+	}
+	if ids := outputIDs(t, out); len(ids) != 3 {
+		t.Errorf("output holds %v, want 3 records", ids)
+	}
+}
+
+// TestTruncateOutput checks that a run with TruncateOutput replaces what
+// earlier runs left in the output files it writes, keeps every line it
+// writes itself, and leaves other files alone.
+func TestTruncateOutput(t *testing.T) {
+	out := t.TempDir()
+	written := filepath.Join(out, "RC_trunc_golang.ndjson")
+	other := filepath.Join(out, "RC_trunc_rust.ndjson")
+	for _, path := range []string{written, other} {
+		if err := os.WriteFile(path, []byte(`{"id":"t1_old"}`+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &Processor{
+		Output:         out,
+		Files:          []string{"RC_trunc.ndjson"},
+		Threads:        2,
+		Fields:         []string{"subreddit"},
+		Values:         []string{"golang", "rust"},
+		FileFilter:     regexp.MustCompile(".*"),
+		Extensions:     []string{".ndjson"},
+		MatchMode:      "exact",
+		TimeField:      "created_utc",
+		TruncateOutput: true,
+		OpenInput: MemoryInput(map[string][]byte{"RC_trunc.ndjson": []byte(
+			`{"id":"t1_a","subreddit":"golang"}` + "\n" +
+				`{"id":"t1_b","subreddit":"golang"}` + "\n")}),
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		written: `{"id":"t1_a","subreddit":"golang"}` + "\n" + `{"id":"t1_b","subreddit":"golang"}` + "\n",
+		other:   `{"id":"t1_old"}` + "\n",
+	} {
+		if b, err := os.ReadFile(path); err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(path), b, err, want)
+		}
+	}
+
+	p.CheckpointInterval = 10
+	if err := p.ProcessAndServe(); err == nil {
+		t.Error("truncating output combined with checkpointing was accepted")
+	}
+}
+
+// TestOutputShards checks that a value always lands in the same shard
+// directory, that shards are named within range, and that values are
+// spread over them.
+func TestOutputShards(t *testing.T) {
+	const shards = 8
+	used := make(map[string]bool)
+	for i := range 200 {
+		value := fmt.Sprintf("value%d", i)
+		dir := shardDir(value, shards)
+		if dir != shardDir(value, shards) {
+			t.Fatalf("value %s moved between shards", value)
+		}
+		var n int
+		if _, err := fmt.Sscanf(dir, "shard-%02d", &n); err != nil || n < 0 || n >= shards {
+			t.Fatalf("shard %q of %s is out of range", dir, value)
+		}
+		used[dir] = true
+	}
+	if len(used) != shards {
+		t.Errorf("200 values used %d of %d shards", len(used), shards)
+	}
+
+	out := t.TempDir()
+	p := &Processor{Output: out, OutputShards: shards, OutputLayout: "by-value"}
+	got, err := p.outputPath("RC_2024-01.zst", "a/b", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(out, shardDir("a/b", shards), "a_b", "RC_2024-01.ndjson"); got != want {
+		t.Errorf("sharded path %s, want %s", got, want)
+	}
+}
+
+// TestRunReport checks the overall and per-file match rates written to
+// report.json, and the flags of files where none or all lines matched.
+func TestRunReport(t *testing.T) {
+	lines := func(n, matching int) []byte {
+		var b []byte
+		for i := range n {
+			sub := "rust"
+			if i < matching {
+				sub = "golang"
+			}
+			b = fmt.Appendf(b, `{"id":"t1_%d","subreddit":%q}`+"\n", i, sub)
+		}
+		return b
+	}
+	out := t.TempDir()
+	p := &Processor{
+		Output:     out,
+		Files:      []string{"RC_all.ndjson", "RC_none.ndjson", "RC_some.ndjson", "RC_small.ndjson"},
+		Threads:    2,
+		Fields:     []string{"subreddit"},
+		Values:     []string{"golang"},
+		FileFilter: regexp.MustCompile(".*"),
+		Extensions: []string{".ndjson"},
+		MatchMode:  "exact",
+		TimeField:  "created_utc",
+		RunReport:  true,
+		OpenInput: MemoryInput(map[string][]byte{
+			"RC_all.ndjson":   lines(200, 200),
+			"RC_none.ndjson":  lines(200, 0),
+			"RC_some.ndjson":  lines(200, 50),
+			"RC_small.ndjson": lines(10, 0),
+		}),
+		OnMatch:  func(_, _ string, _ []byte) {},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(out, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r runReport
+	if err := jsoniter.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Lines != 610 || r.Matched != 250 || r.MatchRate == nil || *r.MatchRate != 250.0/610 {
+		t.Errorf("report totals %d lines, %d matched, rate %v, want 610, 250 and %v", r.Lines, r.Matched, r.MatchRate, 250.0/610)
+	}
+	want := []struct {
+		path    string
+		matched int64
+		flag    string
+	}{
+		{"RC_all.ndjson", 200, "high"},
+		{"RC_none.ndjson", 0, "none"},
+		{"RC_small.ndjson", 0, ""},
+		{"RC_some.ndjson", 50, ""},
+	}
+	if len(r.Files) != len(want) {
+		t.Fatalf("report lists %d files, want %d", len(r.Files), len(want))
+	}
+	for i, w := range want {
+		f := r.Files[i]
+		if f.Path != w.path || f.Matched != w.matched || f.Flag != w.flag {
+			t.Errorf("file %d = %+v, want %s with %d matched and flag %q", i, f, w.path, w.matched, w.flag)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// progress counts finished files and input bytes read so that an external
+// monitor can follow a run through progress.json in the output directory.
+// Bytes are counted as read from the input, before decompression, so that
+// they compare with the file sizes.
+type progress struct {
+	start      time.Time
+	filesTotal int
+	filesDone  atomic.Int64
+	bytesTotal atomic.Int64
+	bytesDone  atomic.Int64
+}
+
+// progressReport is the content of progress.json. ETA is null until the
+// first bytes have been read, and MatchRate until the first lines have
+// been counted; workers report lines in batches, so Lines trails a little
+// until the end. Done is set once the run has ended, whether or not every
+// file was processed.
+type progressReport struct {
+	FilesDone  int64     `json:"files_done"`
+	FilesTotal int       `json:"files_total"`
+	BytesDone  int64     `json:"bytes_done"`
+	BytesTotal int64     `json:"bytes_total"`
+	Lines      int64     `json:"lines_scanned"`
+	Matched    int64     `json:"lines_matched"`
+	MatchRate  *float64  `json:"match_rate"`
+	Workers    int       `json:"workers"`
+	Elapsed    float64   `json:"elapsed_seconds"`
+	ETA        *float64  `json:"eta_seconds"`
+	Done       bool      `json:"done"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// newProgress returns a progress for files with the sizes of the local
+// ones, as returned by size, as the byte total. The sizes of remote files
+// are added as they are opened.
+func newProgress(files []string, size func(string) (int64, error)) *progress {
+	pr := &progress{start: time.Now(), filesTotal: len(files)}
+	for _, file := range files {
+		if isURL(file) {
+			continue
+		}
+		if n, err := size(file); err == nil {
+			pr.bytesTotal.Add(n)
+		}
+	}
+	return pr
+}
+
+func (pr *progress) report(done bool) progressReport {
+	elapsed := time.Since(pr.start)
+	r := progressReport{
+		FilesDone:  pr.filesDone.Load(),
+		FilesTotal: pr.filesTotal,
+		BytesDone:  pr.bytesDone.Load(),
+		BytesTotal: pr.bytesTotal.Load(),
+		Elapsed:    elapsed.Seconds(),
+		Done:       done,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if r.BytesDone > 0 && r.BytesTotal >= r.BytesDone {
+		eta := elapsed.Seconds() * float64(r.BytesTotal-r.BytesDone) / float64(r.BytesDone)
+		r.ETA = &eta
+	}
+	return r
+}
+
+// countingReader adds the number of bytes read to n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// trackProgress writes progress.json every ProgressInterval until stop is
+// closed, then writes it a last time marked as done.
+func (p *Processor) trackProgress(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.writeProgress(false)
+		case <-stop:
+			p.writeProgress(true)
+			return
+		}
+	}
+}
+
+// writeProgress replaces progress.json via a rename so that readers never
+// see a partly written file.
+func (p *Processor) writeProgress(done bool) {
+	path := filepath.Join(p.Output, "progress.json")
+	r := p.progress.report(done)
+	r.Lines, r.Matched = p.scanned.Load(), p.totalMatches()
+	r.MatchRate = matchRate(r.Lines, r.Matched)
+	p.mu.Lock()
+	workers := p.workers
+	p.mu.Unlock()
+	if workers != nil {
+		r.Workers = workers.active()
+	}
+	b, err := jsoniter.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path+".tmp", b, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		p.ErrorLog.Warn("failed to write progress",
+			"path", path,
+			"err", err,
+		)
+	}
+}
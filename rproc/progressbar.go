@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+var barStyle = mpb.BarStyle().Lbound("╢").Filler("▌").Tip("▌").Padding("░").Rbound("╟")
+
+// newFileBar adds a progress bar for one input file.
+func newFileBar(barz *mpb.Progress, name string, total int64) *mpb.Bar {
+	return barz.New(total,
+		barStyle,
+		mpb.PrependDecorators(
+			decor.Name(name+":", decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.Counters(decor.SizeB1024(0), "% .2f / % .2f", decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WCSyncWidth, decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.Name("Avg. ETA:", decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.OnComplete(
+				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+				"done",
+			),
+		),
+	)
+}
+
+// compactBar is the single bar shared by all input files in compact
+// progress mode, with a line below it naming the file last started.
+type compactBar struct {
+	bar     *mpb.Bar
+	status  *mpb.Bar
+	total   atomic.Int64
+	current atomic.Pointer[string]
+}
+
+// newCompactBar adds the bars of compact mode for files of the given total
+// size.
+func newCompactBar(barz *mpb.Progress, files int, total int64) *compactBar {
+	c := &compactBar{}
+	c.total.Store(total)
+	c.bar = barz.New(total,
+		barStyle,
+		mpb.PrependDecorators(
+			decor.Name(fmt.Sprintf("%d files:", files), decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.Counters(decor.SizeB1024(0), "% .2f / % .2f", decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.Name("Avg. ETA:", decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+			decor.OnComplete(
+				decor.AverageETA(decor.ET_STYLE_GO, decor.WC{C: decor.DindentRight | decor.DextraSpace}),
+				"done",
+			),
+		),
+	)
+	c.status = barz.New(0,
+		mpb.NopStyle(),
+		mpb.PrependDecorators(
+			decor.Any(func(decor.Statistics) string {
+				if name := c.current.Load(); name != nil {
+					return "now processing: " + *name
+				}
+				return ""
+			}),
+		),
+	)
+	return c
+}
+
+// start shows file as being processed. The size of remote files, which is
+// only known once they are opened, is added to the total.
+func (c *compactBar) start(file string, size int64) {
+	name := inputName(file)
+	c.current.Store(&name)
+	if isURL(file) && size > 0 {
+		c.bar.SetTotal(c.total.Add(size), false)
+	}
+}
+
+// done completes both bars once all files have been processed.
+func (c *compactBar) done() {
+	c.bar.SetTotal(-1, true)
+	c.status.Abort(true)
+}
@@ -0,0 +1,82 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead caps the bytes read at once through a readLimiter so
+// that waits stay short and the limit is shared fairly between workers.
+const maxThrottledRead = 64 << 10
+
+// readLimiter limits the combined read throughput of all workers to a
+// number of bytes per second. Reads take their bytes on credit and the
+// reader then sleeps until the debt is paid off, so concurrent readers
+// queue behind each other.
+type readLimiter struct {
+	mu    sync.Mutex
+	rate  float64 // bytes per second
+	chunk int
+	debt  time.Time // when the bytes read so far are paid for
+}
+
+func newReadLimiter(bytesPerSec int64) *readLimiter {
+	return &readLimiter{
+		rate:  float64(bytesPerSec),
+		chunk: int(min(max(bytesPerSec/10, 1), maxThrottledRead)),
+	}
+}
+
+// take accounts for n bytes read and returns how long to wait for them.
+func (l *readLimiter) take(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.debt.Before(now) {
+		l.debt = now
+	}
+	l.debt = l.debt.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	return l.debt.Sub(now)
+}
+
+// throttledReader reads through a readLimiter.
+type throttledReader struct {
+	r io.Reader
+	l *readLimiter
+}
+
+func (t *throttledReader) Read(b []byte) (int, error) {
+	if len(b) > t.l.chunk {
+		b = b[:t.l.chunk]
+	}
+	n, err := t.r.Read(b)
+	if n > 0 {
+		time.Sleep(t.l.take(n))
+	}
+	return n, err
+}
@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReadLimiterTake checks that reads are paid for at the configured
+// rate, one after the other, and that idle time is not saved up.
+func TestReadLimiterTake(t *testing.T) {
+	l := newReadLimiter(1000)
+	if l.chunk != 100 {
+		t.Errorf("chunk = %d, want a tenth of the rate", l.chunk)
+	}
+	const slack = 50 * time.Millisecond
+	if d := l.take(500); d < 500*time.Millisecond-slack || d > 500*time.Millisecond {
+		t.Errorf("wait for 500 bytes = %v, want 500ms", d)
+	}
+	if d := l.take(500); d < time.Second-slack || d > time.Second {
+		t.Errorf("wait for 500 more bytes = %v, want 1s", d)
+	}
+
+	l = newReadLimiter(1000)
+	l.debt = time.Now().Add(-time.Hour)
+	if d := l.take(100); d < 100*time.Millisecond-slack || d > 100*time.Millisecond {
+		t.Errorf("wait after an idle hour = %v, want 100ms", d)
+	}
+
+	if l := newReadLimiter(1 << 30); l.chunk != maxThrottledRead {
+		t.Errorf("chunk at a high rate = %d, want %d", l.chunk, maxThrottledRead)
+	}
+	if l := newReadLimiter(3); l.chunk != 1 {
+		t.Errorf("chunk at a low rate = %d, want 1", l.chunk)
+	}
+}
+
+// TestThrottledReader checks that readers sharing a limiter together read
+// no faster than its rate.
+func TestThrottledReader(t *testing.T) {
+	const (
+		rate    = 200 << 10
+		readers = 4
+		size    = 20 << 10
+	)
+	l := newReadLimiter(rate)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &throttledReader{r: bytes.NewReader(make([]byte, size)), l: l}
+			n, err := io.Copy(io.Discard, r)
+			if err != nil || n != size {
+				t.Errorf("read %d bytes, %v, want %d", n, err, size)
+			}
+		}()
+	}
+	wg.Wait()
+	// 80 KiB at 200 KiB/s takes 400ms.
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("read %d bytes in %v, faster than %d bytes/s", readers*size, elapsed, rate)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	jsoniter "github.com/json-iterator/go"
+)
+
+// fieldCheckLines is the number of lines of the first input file read at
+// startup by checkFields.
+const fieldCheckLines = 100
+
+const (
+	schemaUnknown     = "unknown"
+	schemaSubmissions = "submissions"
+	schemaComments    = "comments"
+)
+
+// detectSchema infers whether a record is a submission or a comment from
+// the fields only one of the two kinds carries.
+func detectSchema(line []byte) string {
+	has := func(field string) bool {
+		return jsoniter.Get(line, field).ValueType() != jsoniter.InvalidValue
+	}
+	switch {
+	case has("title") || has("selftext"):
+		return schemaSubmissions
+	case has("body") || has("parent_id"):
+		return schemaComments
+	default:
+		return schemaUnknown
+	}
+}
+
+// checkSchema logs the detected record kind of a file from its first
+// line and warns about configured fields its records do not have.
+func (p *Processor) checkSchema(file string, line []byte) {
+	schema := detectSchema(line)
+	p.ErrorLog.Debug("detected record schema", "path", file, "schema", schema)
+
+	for _, field := range p.Fields {
+		if field == FilenameField {
+			continue
+		}
+		if jsoniter.Get(line, field).ValueType() == jsoniter.InvalidValue {
+			p.ErrorLog.Warn("configured field not present in file records",
+				"path", file,
+				"field", field,
+				"schema", schema,
+			)
+		}
+	}
+}
+
+// checkFields reads the first lines of file and warns about configured
+// fields that none of them has, since a misspelled field otherwise just
+// matches nothing. The warning suggests a similarly named field if any.
+func (p *Processor) checkFields(file string) {
+	if p.MatchMode == "jq" {
+		return
+	}
+	missing := make(map[string]bool)
+	for _, field := range p.Fields {
+		if field != FilenameField {
+			missing[field] = true
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	keys := make(map[string]struct{})
+	n, err := p.readSample(file, fieldCheckLines, func(line []byte) bool {
+		var record map[string]any
+		if err := jsoniter.Unmarshal(line, &record); err != nil {
+			return false
+		}
+		for key := range record {
+			keys[key] = struct{}{}
+			delete(missing, key)
+		}
+		return true
+	})
+	if err != nil {
+		p.ErrorLog.Debug("failed to sample input for field check", "path", file, "err", err)
+		return
+	}
+	if n == 0 {
+		return
+	}
+	for field := range missing {
+		for _, alias := range p.FieldAliases[field] {
+			if _, ok := keys[alias]; ok {
+				delete(missing, field)
+			}
+		}
+	}
+
+	for _, field := range p.Fields {
+		if !missing[field] {
+			continue
+		}
+		args := []any{"field", field, "path", file, "records", n}
+		if similar := similarKey(field, keys); similar != "" {
+			args = append(args, "did_you_mean", similar)
+		}
+		p.ErrorLog.Warn("configured field not found in any sampled record", args...)
+	}
+}
+
+// similarKey returns the key closest to field within two edits, or "".
+func similarKey(field string, keys map[string]struct{}) string {
+	best, bestDist := "", 3
+	for key := range keys {
+		if d := editDistance(field, key); d < bestDist || d == bestDist && key < best {
+			best, bestDist = key, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,230 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// The zstd seekable format appends a skippable frame holding the
+// compressed and decompressed size of every frame, followed by a footer.
+const (
+	seekTableFrameMagic = 0x184d2a5e
+	seekTableMagic      = 0x8f92eab1
+	seekTableFooterSize = 9
+)
+
+// seekFrame is where a frame starts in the compressed file and in the
+// decompressed stream.
+type seekFrame struct {
+	compressed   int64
+	decompressed int64
+}
+
+// readSeekTable returns the frames listed in the seek table at the end of
+// a file of size bytes in the zstd seekable format, or nil if the file has
+// no seek table.
+func readSeekTable(r io.ReaderAt, size int64) ([]seekFrame, error) {
+	if size < seekTableFooterSize+8 {
+		return nil, nil
+	}
+	footer := make([]byte, seekTableFooterSize)
+	if _, err := r.ReadAt(footer, size-seekTableFooterSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekTableMagic {
+		return nil, nil
+	}
+
+	frames := int64(binary.LittleEndian.Uint32(footer[:4]))
+	entrySize := int64(8)
+	if footer[4]&0x80 != 0 {
+		entrySize = 12 // with checksums
+	}
+	tableSize := frames*entrySize + seekTableFooterSize
+	start := size - tableSize - 8
+	if start < 0 {
+		return nil, errors.New("corrupt zstd seek table")
+	}
+	table := make([]byte, 8+frames*entrySize)
+	if _, err := r.ReadAt(table, start); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableFrameMagic || int64(binary.LittleEndian.Uint32(table[4:])) != tableSize {
+		return nil, errors.New("corrupt zstd seek table")
+	}
+
+	result := make([]seekFrame, 0, frames)
+	var c, d int64
+	for entry := table[8:]; len(entry) > 0; entry = entry[entrySize:] {
+		result = append(result, seekFrame{compressed: c, decompressed: d})
+		c += int64(binary.LittleEndian.Uint32(entry))
+		d += int64(binary.LittleEndian.Uint32(entry[4:]))
+	}
+	return result, nil
+}
+
+// seekResume moves a local input in the zstd seekable format to the start
+// of the frame that holds the decompressed offset, so that resuming from a
+// checkpoint or reading a byte range skips the frames before it without
+// decompressing them. It
+// returns the decompressed offset reading continues from, and false if the
+// input was left at its start.
+func (p *Processor) seekResume(file string, input io.Reader, size, offset int64) (int64, bool) {
+	f, ok := input.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	table, err := readSeekTable(f, size)
+	if err != nil {
+		p.ErrorLog.Warn("ignoring zstd seek table", "path", file, "err", err)
+		return 0, false
+	}
+	i := sort.Search(len(table), func(i int) bool { return table[i].decompressed > offset }) - 1
+	if i <= 0 {
+		return 0, false
+	}
+	if _, err := f.Seek(table[i].compressed, io.SeekStart); err != nil {
+		p.ErrorLog.Warn("failed to seek input", "path", file, "err", err)
+		return 0, false
+	}
+	p.ErrorLog.Info("seeking input with zstd seek table",
+		"path", file,
+		"offset", offset,
+		"frame", i,
+		"frames", len(table),
+	)
+	return table[i].decompressed, true
+}
+
+// inputTask is the part of an input one worker reads: the lines starting
+// within length bytes after offset of its decompressed content, like
+// RangeOffset and RangeLength. An input split into chunks has one task per
+// chunk, numbered from 1 in chunk, out of parts.
+type inputTask struct {
+	file   string
+	offset int64
+	length int64
+	size   int64 // compressed bytes of a chunk, for its progress bar
+	chunk  int
+	parts  int
+	left   *atomic.Int64 // chunks of the input not yet finished
+}
+
+// name is the name of the input for progress bars, with the chunk number
+// if it is split.
+func (t inputTask) name() string {
+	if t.parts == 0 {
+		return inputName(t.file)
+	}
+	return fmt.Sprintf("%s [%d/%d]", inputName(t.file), t.chunk, t.parts)
+}
+
+// finish records that the worker of t has ended and reports whether it was
+// the last one reading the input.
+func (t inputTask) finish() bool {
+	return t.left == nil || t.left.Add(-1) == 0
+}
+
+// splitInputs returns the tasks for files: one per chunk for inputs split
+// by seekChunks and one covering the byte range for every other input.
+func (p *Processor) splitInputs(files []string) []inputTask {
+	tasks := make([]inputTask, 0, len(files))
+	for _, file := range files {
+		if chunks := p.seekChunks(file); len(chunks) > 1 {
+			tasks = append(tasks, chunks...)
+			continue
+		}
+		tasks = append(tasks, inputTask{file: file, offset: p.RangeOffset, length: p.RangeLength})
+	}
+	return tasks
+}
+
+// seekChunks splits a local input in the zstd seekable format at frame
+// boundaries into chunks of at least SeekableChunkSize decompressed bytes,
+// so that several workers can read it at once, each seeking to its first
+// frame. It returns nil for inputs that are not split.
+func (p *Processor) seekChunks(file string) []inputTask {
+	if p.SeekableChunkSize <= 0 || p.OpenInput != nil || isURL(file) || !isZstd(file) {
+		return nil
+	}
+	if _, _, ok := splitZipEntry(file); ok {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		// Left to the worker to report.
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	table, err := readSeekTable(f, info.Size())
+	if err != nil {
+		p.ErrorLog.Warn("ignoring zstd seek table", "path", file, "err", err)
+		return nil
+	}
+	if len(table) == 0 {
+		return nil
+	}
+	if isArray, err := p.isArrayInput(file); err != nil || isArray {
+		// The elements of an array have no byte offsets to split at.
+		return nil
+	}
+
+	var chunks []inputTask
+	start := table[0]
+	for _, frame := range table[1:] {
+		if frame.decompressed-start.decompressed < p.SeekableChunkSize {
+			continue
+		}
+		chunks = append(chunks, inputTask{
+			file:   file,
+			offset: start.decompressed,
+			length: frame.decompressed - start.decompressed,
+			size:   frame.compressed - start.compressed,
+		})
+		start = frame
+	}
+	chunks = append(chunks, inputTask{file: file, offset: start.decompressed, size: info.Size() - start.compressed})
+	if len(chunks) == 1 {
+		return nil
+	}
+	left := new(atomic.Int64)
+	left.Store(int64(len(chunks)))
+	for i := range chunks {
+		chunks[i].chunk, chunks[i].parts, chunks[i].left = i+1, len(chunks), left
+	}
+	p.ErrorLog.Info("splitting input at zstd frames", "path", file, "chunks", len(chunks))
+	return chunks
+}
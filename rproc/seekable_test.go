@@ -0,0 +1,120 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+)
+
+// seekableZstd compresses data in the zstd seekable format as frames of
+// frameSize decompressed bytes, cut without regard to where lines end.
+func seekableZstd(t *testing.T, data []byte, frameSize int) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	var out, table bytes.Buffer
+	frames := 0
+	for len(data) > 0 {
+		n := min(frameSize, len(data))
+		frame := enc.EncodeAll(data[:n], nil)
+		out.Write(frame)
+		binary.Write(&table, binary.LittleEndian, [2]uint32{uint32(len(frame)), uint32(n)})
+		data = data[n:]
+		frames++
+	}
+	binary.Write(&out, binary.LittleEndian, [2]uint32{seekTableFrameMagic, uint32(table.Len() + seekTableFooterSize)})
+	out.Write(table.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(frames))
+	out.WriteByte(0)
+	binary.Write(&out, binary.LittleEndian, uint32(seekTableMagic))
+	return out.Bytes()
+}
+
+// TestSeekableChunks checks that splitting a seekable input into chunks
+// read by several workers matches every record exactly once, including
+// the lines that cross the boundary between two chunks.
+func TestSeekableChunks(t *testing.T) {
+	const records = 2000
+	var data bytes.Buffer
+	for i := range records {
+		fmt.Fprintf(&data, `{"id":"t3_%d","subreddit":"golang"}`+"\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "RC_seekable.zst")
+	if err := os.WriteFile(path, seekableZstd(t, data.Bytes(), 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	p := &Processor{
+		Output:            t.TempDir(),
+		Files:             []string{path},
+		Threads:           4,
+		SeekableChunkSize: 3000,
+		Fields:            []string{"subreddit"},
+		Values:            []string{"golang"},
+		FileFilter:        regexp.MustCompile(".*"),
+		Extensions:        []string{".zst"},
+		MatchMode:         "exact",
+		TimeField:         "created_utc",
+		OnMatch: func(_, _ string, line []byte) {
+			mu.Lock()
+			seen[jsoniter.Get(line, "id").ToString()]++
+			mu.Unlock()
+		},
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if chunks := p.seekChunks(path); len(chunks) < 2 {
+		t.Fatalf("split into %d chunks, want several", len(chunks))
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != records {
+		t.Errorf("matched %d distinct records, want %d", len(seen), records)
+	}
+	for i := range records {
+		if id := fmt.Sprintf("t3_%d", i); seen[id] != 1 {
+			t.Errorf("record %s matched %d times, want 1", id, seen[id])
+		}
+	}
+}
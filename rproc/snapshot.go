@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"cmp"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// LogSnapshot logs the state of a run in progress without interrupting
+// it: the lines read and matched so far, the match rate, whether the run
+// is paused, and how often each value has matched, most frequent first.
+// Values that have not matched yet are left out. Line counts are reported
+// by the workers in batches, so they trail the matches slightly.
+func (p *Processor) LogSnapshot() {
+	p.mu.Lock()
+	counts := p.matchCounts
+	p.mu.Unlock()
+
+	var matched int64
+	values := make(map[string]int64)
+	for value, count := range counts {
+		if n := count.Load(); n > 0 {
+			values[value] = n
+			matched += n
+		}
+	}
+	sorted := slices.SortedFunc(maps.Keys(values), func(a, b string) int {
+		return cmp.Or(cmp.Compare(values[b], values[a]), cmp.Compare(a, b))
+	})
+	attrs := make([]any, len(sorted))
+	for i, value := range sorted {
+		attrs[i] = slog.Int64(value, values[value])
+	}
+
+	lines := p.scanned.Load()
+	args := []any{"lines", lines, "matched", matched}
+	if rate := matchRate(lines, matched); rate != nil {
+		args = append(args, "rate", formatRate(*rate))
+	}
+	args = append(args, "paused", p.Paused(), slog.Group("values", attrs...))
+	p.ErrorLog.Info("status snapshot", args...)
+}
@@ -0,0 +1,324 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	defaultSortBufferLines = 1_000_000
+)
+
+// sortKey orders records by a field that may hold numbers or strings.
+// Numbers sort before strings and missing values sort last.
+type sortKey struct {
+	kind int // 0 number, 1 string, 2 missing
+	num  float64
+	str  string
+}
+
+func newSortKey(line []byte, field string) sortKey {
+	v := jsoniter.Get(line, field)
+	switch v.ValueType() {
+	case jsoniter.NumberValue:
+		return sortKey{kind: 0, num: v.ToFloat64()}
+	case jsoniter.InvalidValue, jsoniter.NilValue:
+		return sortKey{kind: 2}
+	default:
+		return sortKey{kind: 1, str: v.ToString()}
+	}
+}
+
+func (k sortKey) less(o sortKey) bool {
+	if k.kind != o.kind {
+		return k.kind < o.kind
+	}
+	if k.kind == 0 {
+		return k.num < o.num
+	}
+	return k.str < o.str
+}
+
+// encode serializes the key for a spilled run. The encoding never
+// contains a tab, which separates it from the line.
+func (k sortKey) encode() string {
+	switch k.kind {
+	case 0:
+		return "n" + strconv.FormatFloat(k.num, 'g', -1, 64)
+	case 1:
+		return "s" + strconv.Quote(k.str)
+	default:
+		return "-"
+	}
+}
+
+func decodeSortKey(s string) (sortKey, error) {
+	switch {
+	case s == "-":
+		return sortKey{kind: 2}, nil
+	case strings.HasPrefix(s, "n"):
+		num, err := strconv.ParseFloat(s[1:], 64)
+		return sortKey{kind: 0, num: num}, err
+	case strings.HasPrefix(s, "s"):
+		str, err := strconv.Unquote(s[1:])
+		return sortKey{kind: 1, str: str}, err
+	default:
+		return sortKey{}, fmt.Errorf("invalid sort key %q", s)
+	}
+}
+
+type sortRecord struct {
+	key  sortKey
+	line string
+}
+
+// sortBuffer holds the records of one output file. Once more than the
+// buffer limit accumulate they are sorted and spilled to a temporary run
+// file, and the runs are merged when the output is flushed.
+type sortBuffer struct {
+	records []sortRecord
+	runs    []string
+}
+
+// sorter buffers written lines per output file so that each file can be
+// written sorted by a field once processing has finished.
+type sorter struct {
+	mu      sync.Mutex
+	limit   int
+	eol     string
+	open    func(path string) (io.WriteCloser, error)
+	buffers map[string]*sortBuffer
+}
+
+// newSorter returns a sorter holding at most limit lines per output file
+// in memory, which opens output files for appending with open and ends
+// the lines written to them with eol.
+func newSorter(limit int, eol string, open func(path string) (io.WriteCloser, error)) *sorter {
+	if limit <= 0 {
+		limit = defaultSortBufferLines
+	}
+	return &sorter{
+		limit:   limit,
+		eol:     eol,
+		open:    open,
+		buffers: make(map[string]*sortBuffer),
+	}
+}
+
+func (s *sorter) add(path string, key sortKey, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf, ok := s.buffers[path]
+	if !ok {
+		buf = &sortBuffer{}
+		s.buffers[path] = buf
+	}
+	buf.records = append(buf.records, sortRecord{key: key, line: line})
+	if len(buf.records) < s.limit {
+		return nil
+	}
+	return buf.spill(path)
+}
+
+func (b *sortBuffer) sort() {
+	sort.SliceStable(b.records, func(i, j int) bool {
+		return b.records[i].key.less(b.records[j].key)
+	})
+}
+
+func (b *sortBuffer) spill(path string) error {
+	b.sort()
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".sort-*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range b.records {
+		w.WriteString(r.key.encode())
+		w.WriteByte('\t')
+		w.WriteString(r.line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	b.runs = append(b.runs, f.Name())
+	b.records = b.records[:0]
+	return nil
+}
+
+// flush appends the sorted contents of every buffered output file and
+// removes any temporary run files.
+func (s *sorter) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for path, buf := range s.buffers {
+		if err := buf.flush(path, s.eol, s.open); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+		for _, run := range buf.runs {
+			os.Remove(run)
+		}
+	}
+	s.buffers = make(map[string]*sortBuffer)
+	return errors.Join(errs...)
+}
+
+func (b *sortBuffer) flush(path, eol string, open func(string) (io.WriteCloser, error)) error {
+	out, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	if len(b.runs) == 0 {
+		b.sort()
+		for _, r := range b.records {
+			w.WriteString(r.line)
+			w.WriteString(eol)
+		}
+		return w.Flush()
+	}
+
+	if len(b.records) > 0 {
+		if err := b.spill(path); err != nil {
+			return err
+		}
+	}
+	if err := mergeRuns(b.runs, eol, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type runReader struct {
+	scanner *bufio.Scanner
+	head    sortRecord
+	order   int
+}
+
+func (r *runReader) next() (bool, error) {
+	if !r.scanner.Scan() {
+		return false, r.scanner.Err()
+	}
+	encoded, line, ok := strings.Cut(r.scanner.Text(), "\t")
+	if !ok {
+		return false, errors.New("corrupt sort run")
+	}
+	key, err := decodeSortKey(encoded)
+	if err != nil {
+		return false, err
+	}
+	r.head = sortRecord{key: key, line: line}
+	return true, nil
+}
+
+// runHeap orders run readers by their head record. Ties go to the earlier
+// run so that the merge is stable.
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if h[i].head.key.less(h[j].head.key) {
+		return true
+	}
+	if h[j].head.key.less(h[i].head.key) {
+		return false
+	}
+	return h[i].order < h[j].order
+}
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)   { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// mergeRuns performs a k-way merge of sorted run files into w, ending
+// each line with eol.
+func mergeRuns(runs []string, eol string, w io.Writer) error {
+	h := make(runHeap, 0, len(runs))
+	for i, run := range runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64<<10), 512<<20)
+		r := &runReader{scanner: scanner, order: i}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		r := h[0]
+		if _, err := io.WriteString(w, r.head.line+eol); err != nil {
+			return err
+		}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSorter checks the order of sorted output, both when it fits in
+// memory and when it is spilled to runs and merged, and that no run files
+// are left behind.
+func TestSorter(t *testing.T) {
+	lines := []string{
+		`{"id":"a","k":3}`,
+		`{"id":"b","k":"x"}`,
+		`{"id":"c"}`,
+		`{"id":"d","k":-1.5}`,
+		`{"id":"e","k":3}`,
+		`{"id":"f","k":"tab\there"}`,
+		`{"id":"g","k":null}`,
+		`{"id":"h","k":10}`,
+		`{"id":"i","k":"w"}`,
+	}
+	// Numbers first, then strings, then missing values; ties keep their
+	// input order.
+	want := []string{"d", "a", "e", "h", "f", "i", "b", "c", "g"}
+
+	for _, limit := range []int{0, 2, 4} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.ndjson")
+		s := newSorter(limit, "\n", func(path string) (io.WriteCloser, error) {
+			return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		})
+		for _, line := range lines {
+			if err := s.add(path, newSortKey([]byte(line), "k"), line); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := outputIDs(t, dir); strings.Join(ids, "") != strings.Join(want, "") {
+			t.Errorf("limit %d: sorted ids %v, want %v", limit, ids, want)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("limit %d: %d files left in the output directory, want 1", limit, len(entries))
+		}
+	}
+}
+
+func TestSortKeyEncoding(t *testing.T) {
+	for _, k := range []sortKey{
+		{kind: 0, num: -1.5},
+		{kind: 0, num: 1e300},
+		{kind: 1, str: "tab\there\nand newline"},
+		{kind: 1},
+		{kind: 2},
+	} {
+		enc := k.encode()
+		if strings.ContainsAny(enc, "\t\n") {
+			t.Errorf("encoding of %+v contains a separator: %q", k, enc)
+		}
+		if got, err := decodeSortKey(enc); err != nil || got != k {
+			t.Errorf("decodeSortKey(%q) = %+v, %v, want %+v", enc, got, err, k)
+		}
+	}
+	if _, err := decodeSortKey("x1"); err == nil {
+		t.Error("invalid sort key decoded without error")
+	}
+}
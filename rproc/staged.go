@@ -0,0 +1,126 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// stagedOutput tracks the temporary files written for each input when
+// AtomicOutput is set, so that they are only renamed to their final names
+// once the input has been processed completely. An input split into chunks
+// is complete once every chunk is.
+type stagedOutput struct {
+	mu       sync.Mutex
+	files    map[string]map[string]struct{} // input -> final output paths
+	complete map[string]int                 // input -> chunks read to the end
+	parts    map[string]int
+}
+
+func newStagedOutput() *stagedOutput {
+	return &stagedOutput{
+		files:    make(map[string]map[string]struct{}),
+		complete: make(map[string]int),
+		parts:    make(map[string]int),
+	}
+}
+
+// expectParts records that input is read in parts chunks, or in one piece
+// if parts is zero.
+func (s *stagedOutput) expectParts(input string, parts int) {
+	s.mu.Lock()
+	s.parts[input] = max(parts, 1)
+	s.mu.Unlock()
+}
+
+// stage returns the temporary path to write instead of final. A leftover
+// temporary file from an earlier, interrupted run is removed the first
+// time it is staged.
+func (s *stagedOutput) stage(input, final string) (string, error) {
+	tmp := final + ".tmp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outputs, ok := s.files[input]
+	if !ok {
+		outputs = make(map[string]struct{})
+		s.files[input] = outputs
+	}
+	if _, ok := outputs[final]; !ok {
+		if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		outputs[final] = struct{}{}
+	}
+	return tmp, nil
+}
+
+// markComplete records that input, or one chunk of it, was read to the
+// end without errors.
+func (s *stagedOutput) markComplete(input string) {
+	s.mu.Lock()
+	s.complete[input]++
+	s.mu.Unlock()
+}
+
+// isComplete reports whether all of input was read. s.mu must be held.
+func (s *stagedOutput) isComplete(input string) bool {
+	return s.complete[input] >= max(s.parts[input], 1)
+}
+
+// commitStaged renames the temporary files of complete inputs to their final
+// names and removes those of inputs that failed or were interrupted.
+func (p *Processor) commitStaged() {
+	s := p.staged
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for input, outputs := range s.files {
+		for final := range outputs {
+			tmp := final + ".tmp"
+			if !s.isComplete(input) {
+				if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					p.ErrorLog.Warn("failed to remove partial output", "path", tmp, "err", err)
+				}
+				if p.checksums != nil {
+					p.checksums.discard(tmp)
+				}
+				continue
+			}
+			if err := os.Rename(tmp, final); err != nil {
+				p.ErrorLog.Error("failed to move output into place", "path", final, "err", err)
+				continue
+			}
+			if p.checksums != nil {
+				p.checksums.rename(tmp, final)
+			}
+		}
+		if !s.isComplete(input) {
+			p.ErrorLog.Warn("discarded partial output of incomplete input", "path", input, "files", len(outputs))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestCommitStaged checks that temporary files replace their final files
+// only for inputs read completely, including every chunk of a split one,
+// and are removed otherwise.
+func TestCommitStaged(t *testing.T) {
+	dir := t.TempDir()
+	p := &Processor{staged: newStagedOutput(), ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	s := p.staged
+
+	leftover := filepath.Join(dir, "done.ndjson")
+	if err := os.WriteFile(leftover+".tmp", []byte("stale\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leftover, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stage := func(input, final, content string) {
+		t.Helper()
+		tmp, err := s.stage(input, final)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tmp != final+".tmp" {
+			t.Fatalf("stage(%s) = %s, want %s.tmp", final, tmp, final)
+		}
+		f, err := os.OpenFile(tmp, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(content)
+		f.Close()
+	}
+	stage("done.zst", leftover, "new\n")
+	stage("done.zst", leftover, "more\n")
+	s.markComplete("done.zst")
+
+	partial := filepath.Join(dir, "partial.ndjson")
+	stage("partial.zst", partial, "cut off\n")
+
+	split := filepath.Join(dir, "split.ndjson")
+	s.expectParts("split.zst", 2)
+	stage("split.zst", split, "half\n")
+	s.markComplete("split.zst")
+
+	p.commitStaged()
+
+	if b, err := os.ReadFile(leftover); err != nil || string(b) != "new\nmore\n" {
+		t.Errorf("complete output = %q, %v, want the staged lines only", b, err)
+	}
+	for _, path := range []string{leftover + ".tmp", partial, partial + ".tmp", split, split + ".tmp"} {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s exists after commit, err = %v", path, err)
+		}
+	}
+}
+
+// TestAtomicOutputReplaces checks that a run with atomic output replaces
+// an existing output file instead of appending to it.
+func TestAtomicOutputReplaces(t *testing.T) {
+	out := t.TempDir()
+	final := filepath.Join(out, "RC_atomic_golang.ndjson")
+	if err := os.WriteFile(final, []byte(`{"id":"t1_old"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Processor{
+		Output:       out,
+		Files:        []string{"RC_atomic.ndjson"},
+		Threads:      1,
+		Fields:       []string{"subreddit"},
+		Values:       []string{"golang"},
+		FileFilter:   regexp.MustCompile(".*"),
+		Extensions:   []string{".ndjson"},
+		MatchMode:    "exact",
+		TimeField:    "created_utc",
+		AtomicOutput: true,
+		OpenInput: MemoryInput(map[string][]byte{
+			"RC_atomic.ndjson": []byte(`{"id":"t1_new","subreddit":"golang"}` + "\n"),
+		}),
+		ErrorLog: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := p.ProcessAndServe(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := outputIDs(t, out); len(ids) != 1 || ids[0] != "t1_new" {
+		t.Errorf("output holds %v, want only t1_new", ids)
+	}
+	if _, err := os.Stat(final + ".tmp"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("temporary file left behind, err = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// streamRecord is one matched line as sent to StreamURL.
+type streamRecord struct {
+	File  string `json:"file"`
+	Value string `json:"value"`
+	Line  string `json:"line"`
+}
+
+// stream sends matched records to StreamURL as NDJSON in the body of a
+// single chunked POST request. Writes go straight into the request body,
+// so a receiver that reads slowly holds up the workers through TCP flow
+// control instead of records piling up in memory.
+type stream struct {
+	mu      sync.Mutex
+	url     string
+	log     *slog.Logger
+	body    *io.PipeWriter
+	done    chan struct{}
+	err     error // result of the request, set before done is closed
+	failed  bool
+	dropped int64
+}
+
+// openStream starts the request to url. It runs until close is called.
+func openStream(client *http.Client, url string, log *slog.Logger) (*stream, error) {
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, url, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	s := &stream{url: url, log: log, body: pw, done: make(chan struct{})}
+	go func() {
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("unexpected status %s", resp.Status)
+			}
+		}
+		// Unblock writers if the request ended before the body did.
+		pr.Close()
+		s.err = err
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// send writes one record and reports whether it was handed to the
+// request. Once a write has failed, the failure is logged and further
+// records are only counted as dropped.
+func (s *stream) send(file, value, line string) bool {
+	b, err := jsoniter.Marshal(streamRecord{File: file, Value: value, Line: line})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.log.Warn("failed to encode streamed record", "path", file, "err", err)
+		s.dropped++
+		return false
+	}
+	b = append(b, '\n')
+	if s.failed {
+		s.dropped++
+		return false
+	}
+	if _, err := s.body.Write(b); err != nil {
+		// The request has ended; report why rather than the closed pipe.
+		<-s.done
+		if s.err != nil {
+			err = s.err
+		}
+		s.failed = true
+		s.dropped++
+		s.log.Error("failed to stream matched records", "url", s.url, "err", err)
+		return false
+	}
+	return true
+}
+
+// close ends the request body and waits for the receiver to respond, so
+// every record sent has been handed over when it returns. It returns an
+// error if any record was dropped or the receiver did not accept the
+// request.
+func (s *stream) close() error {
+	s.mu.Lock()
+	s.body.Close()
+	s.mu.Unlock()
+	<-s.done
+	if s.err != nil && !s.failed {
+		s.log.Error("failed to stream matched records", "url", s.url, "err", s.err)
+	}
+	if s.dropped > 0 {
+		s.log.Warn("dropped matched records that could not be streamed", "count", s.dropped)
+		return fmt.Errorf("stream: %d matched records could not be streamed", s.dropped)
+	}
+	if s.err != nil {
+		return fmt.Errorf("stream: %w", s.err)
+	}
+	return nil
+}
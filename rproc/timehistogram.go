@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// timeBucketFormats maps the TimeHistogram units to the layout of their
+// bucket labels. The labels sort chronologically as strings.
+var timeBucketFormats = map[string]string{
+	"hour":  "2006-01-02T15:00Z",
+	"day":   "2006-01-02",
+	"week":  "2006-01-02",
+	"month": "2006-01",
+	"year":  "2006",
+}
+
+// timeHistogram counts matches per time bucket of TimeField.
+type timeHistogram struct {
+	unit    string
+	mu      sync.Mutex
+	counts  map[string]int64
+	missing atomic.Int64
+}
+
+func newTimeHistogram(unit string) (*timeHistogram, error) {
+	if _, ok := timeBucketFormats[unit]; !ok {
+		return nil, fmt.Errorf("unknown time histogram unit %q, expected hour, day, week, month or year", unit)
+	}
+	return &timeHistogram{unit: unit, counts: make(map[string]int64)}, nil
+}
+
+// bucket returns the label of the bucket holding t. Weeks start on Monday
+// and are labelled with its date.
+func (h *timeHistogram) bucket(t time.Time) string {
+	if h.unit == "week" {
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t.Format(timeBucketFormats[h.unit])
+}
+
+// add counts line in the bucket of its timestamp in field. Lines without
+// a usable timestamp are only counted as missing.
+func (h *timeHistogram) add(line []byte, field string) {
+	t, ok := lineTime(line, field)
+	if !ok {
+		h.missing.Add(1)
+		return
+	}
+	label := h.bucket(t)
+	h.mu.Lock()
+	h.counts[label]++
+	h.mu.Unlock()
+}
+
+// writeTimeHistogram writes bucket,count rows in chronological order to
+// time_histogram.csv in the output directory.
+func (p *Processor) writeTimeHistogram() {
+	path := filepath.Join(p.Output, "time_histogram.csv")
+	if err := p.timeHist.write(path); err != nil {
+		p.ErrorLog.Error("failed to write time histogram", "path", path, "err", err)
+		return
+	}
+	p.ErrorLog.Info("wrote time histogram", "path", path, "buckets", len(p.timeHist.counts))
+	if n := p.timeHist.missing.Load(); n > 0 {
+		p.ErrorLog.Warn("left matches without a timestamp out of the time histogram",
+			"field", p.TimeField,
+			"count", n,
+		)
+	}
+}
+
+func (h *timeHistogram) write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"bucket", "count"})
+	for _, label := range slices.Sorted(maps.Keys(h.counts)) {
+		w.Write([]string{label, strconv.FormatInt(h.counts[label], 10)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
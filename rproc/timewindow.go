@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ParseTime parses a timestamp given either as epoch seconds or RFC3339.
+// An empty string yields the zero time.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(int64(secs), 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: expected epoch seconds or RFC3339", s)
+	}
+	return t, nil
+}
+
+// lineTime extracts the timestamp stored in field of line. Reddit dumps
+// store created_utc as a number in some eras and a string in others.
+func lineTime(line []byte, field string) (time.Time, bool) {
+	v := jsoniter.Get(line, field)
+	switch v.ValueType() {
+	case jsoniter.NumberValue:
+		return time.Unix(int64(v.ToFloat64()), 0).UTC(), true
+	case jsoniter.StringValue:
+		t, err := ParseTime(v.ToString())
+		return t, err == nil && !t.IsZero()
+	default:
+		return time.Time{}, false
+	}
+}
+
+// inTimeWindow reports whether line falls inside the configured inclusive
+// time window. Lines without a usable timestamp are outside any window.
+func (p *Processor) inTimeWindow(line []byte) bool {
+	if p.TimeStart.IsZero() && p.TimeEnd.IsZero() {
+		return true
+	}
+	t, ok := lineTime(line, p.TimeField)
+	if !ok {
+		return false
+	}
+	if !p.TimeStart.IsZero() && t.Before(p.TimeStart) {
+		return false
+	}
+	if !p.TimeEnd.IsZero() && t.After(p.TimeEnd) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"1640995200", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"1640995200.9", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2022-01-01T00:00:00Z", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2022-01-01T02:00:00+02:00", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{"2022-01-01", "yesterday"} {
+		if _, err := ParseTime(in); err == nil {
+			t.Errorf("ParseTime(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+// TestInTimeWindow checks that both ends of the window are inclusive, that
+// timestamps are read from numbers and strings, and that lines without a
+// usable timestamp are outside the window.
+func TestInTimeWindow(t *testing.T) {
+	p := &Processor{
+		TimeField: "created_utc",
+		TimeStart: time.Unix(1000, 0),
+		TimeEnd:   time.Unix(2000, 0),
+	}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{"created_utc":999}`, false},
+		{`{"created_utc":1000}`, true},
+		{`{"created_utc":1500.5}`, true},
+		{`{"created_utc":2000}`, true},
+		{`{"created_utc":2001}`, false},
+		{`{"created_utc":"1500"}`, true},
+		{`{"created_utc":"1970-01-01T00:25:00Z"}`, true},
+		{`{"created_utc":"1970-01-01T01:00:00Z"}`, false},
+		{`{"created_utc":"soon"}`, false},
+		{`{"created_utc":null}`, false},
+		{`{}`, false},
+	}
+	for _, tt := range tests {
+		if got := p.inTimeWindow([]byte(tt.line)); got != tt.want {
+			t.Errorf("inTimeWindow(%s) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	p.TimeStart = time.Time{}
+	if !p.inTimeWindow([]byte(`{"created_utc":1}`)) {
+		t.Error("window without a start rejected an early line")
+	}
+	p.TimeEnd = time.Time{}
+	if !p.inTimeWindow([]byte(`{}`)) {
+		t.Error("line without a timestamp rejected when no window is set")
+	}
+}
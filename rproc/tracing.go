@@ -0,0 +1,234 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// exportTimeout bounds each export of finished spans.
+const exportTimeout = 10 * time.Second
+
+// tracer records spans of a run and exports them to an OpenTelemetry
+// collector as OTLP over HTTP, in the JSON encoding, so that tracing needs
+// no dependencies. A nil tracer records nothing, and so do the nil spans
+// it returns, which keeps tracing free when OTelEndpoint is unset.
+type tracer struct {
+	url     string
+	traceID string
+
+	mu       sync.Mutex
+	finished []*span
+}
+
+func newTracer(endpoint string) *tracer {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &tracer{url: url, traceID: randomID(16)}
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// span is one timed operation of a run.
+type span struct {
+	t      *tracer
+	id     string
+	parent string
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  []map[string]any
+	errMsg string
+}
+
+type spanKey struct{}
+
+// start begins a span named name as a child of the span in ctx, if any,
+// and returns a context carrying it.
+func (t *tracer) start(ctx context.Context, name string) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &span{t: t, id: randomID(8), name: name, start: time.Now()}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.parent = parent.id
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// set adds an attribute. Integers, floats and bools keep their type and
+// everything else is recorded as a string.
+func (s *span) set(key string, value any) {
+	if s == nil {
+		return
+	}
+	var v map[string]any
+	switch value := value.(type) {
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(value)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": value}
+	case bool:
+		v = map[string]any{"boolValue": value}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(value)}
+	}
+	s.attrs = append(s.attrs, map[string]any{"key": key, "value": v})
+}
+
+// fail marks the span as failed with err.
+func (s *span) fail(err any) {
+	if s == nil || err == nil {
+		return
+	}
+	s.errMsg = fmt.Sprint(err)
+}
+
+// finish ends the span and queues it for the next export.
+func (s *span) finish() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.t.mu.Lock()
+	s.t.finished = append(s.t.finished, s)
+	s.t.mu.Unlock()
+}
+
+// traceparent returns the W3C Trace Context header value for requests
+// made on behalf of the span.
+func (s *span) traceparent() string {
+	return "00-" + s.t.traceID + "-" + s.id + "-01"
+}
+
+// setTraceparent adds the traceparent header for the span in ctx, if any,
+// so that servers taking part in the trace can link their spans to it.
+func setTraceparent(ctx context.Context, req *http.Request) {
+	if s, ok := ctx.Value(spanKey{}).(*span); ok && s != nil {
+		req.Header.Set("traceparent", s.traceparent())
+	}
+}
+
+// traceContext returns the context carrying the span of the run, or the
+// background context when Serve is called on its own.
+func (p *Processor) traceContext() context.Context {
+	if p.traceCtx == nil {
+		return context.Background()
+	}
+	return p.traceCtx
+}
+
+// exportSpans sends the spans finished since the last export, logging failures
+// instead of failing the run.
+func (p *Processor) exportSpans() {
+	t := p.tracer
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	finished := t.finished
+	t.finished = nil
+	t.mu.Unlock()
+	if len(finished) == 0 {
+		return
+	}
+
+	spans := make([]map[string]any, len(finished))
+	for i, s := range finished {
+		if s.attrs == nil {
+			s.attrs = []map[string]any{}
+		}
+		out := map[string]any{
+			"traceId":           t.traceID,
+			"spanId":            s.id,
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        s.attrs,
+		}
+		if s.parent != "" {
+			out["parentSpanId"] = s.parent
+		}
+		if s.errMsg != "" {
+			out["status"] = map[string]any{"code": 2, "message": s.errMsg}
+		}
+		spans[i] = out
+	}
+	body, err := jsoniter.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []any{
+				map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "r-proc"}},
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/acquisitionist/r-proc/rproc"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		p.ErrorLog.Warn("failed to encode trace spans", "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		p.ErrorLog.Warn("failed to export trace spans", "url", t.url, "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		p.ErrorLog.Warn("failed to export trace spans", "url", t.url, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		p.ErrorLog.Warn("failed to export trace spans", "url", t.url, "status", resp.Status)
+		return
+	}
+	p.ErrorLog.Debug("exported trace spans", "url", t.url, "spans", len(spans))
+}
@@ -0,0 +1,133 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+)
+
+// watch polls the Input directory for files that were not part of an
+// earlier pass and processes each once its size and modification time
+// stayed the same between two polls, so files still being copied in are
+// not read half-written. It returns when the processor shuts down or a
+// pass fails.
+//
+// Polling is used rather than file system notifications: discover walks
+// Input recursively and into zip archives, which inotify does not watch,
+// notifications are not delivered for network mounts, where dumps often
+// live, and telling a finished copy from one in progress takes a second
+// look at the file anyway. The cost is one walk of Input per interval,
+// and a new file is picked up one to two intervals after its last write.
+func (p *Processor) watch(processed []string) error {
+	interval := p.WatchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	stop := make(chan struct{})
+	p.mu.Lock()
+	p.onShutdown = append(p.onShutdown, func() { close(stop) })
+	p.mu.Unlock()
+	if p.shuttingDown() {
+		return ErrProcessClosed
+	}
+
+	seen := make(map[string]bool, len(processed))
+	for _, file := range processed {
+		seen[file] = true
+	}
+	states := make(map[string]fileState)
+
+	p.watching = true
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	p.ErrorLog.Info("watching input for new files", "input", p.Input, "interval", interval)
+	for {
+		select {
+		case <-stop:
+			return ErrProcessClosed
+		case <-ticker.C:
+		}
+
+		// Archives may have changed since the last scan.
+		p.zips.close()
+		files, err := p.discover()
+		if err != nil {
+			p.ErrorLog.Warn("failed to scan input", "input", p.Input, "err", err)
+			continue
+		}
+		ready := settled(files, seen, states)
+		for _, file := range ready {
+			p.ErrorLog.Info("found new input file", "path", file)
+		}
+		if len(ready) == 0 {
+			continue
+		}
+		if err := p.Serve(ready); err != nil {
+			return err
+		}
+	}
+}
+
+// fileState is what a watch poll found out about a file.
+type fileState struct {
+	size int64
+	mod  time.Time
+}
+
+// settled returns the files not in seen whose size and modification time
+// are the ones recorded in states by the previous poll, and adds them to
+// seen. The other files have their current state recorded for the next
+// poll. Entries of a zip archive take the state of the archive.
+func settled(files []string, seen map[string]bool, states map[string]fileState) []string {
+	var ready []string
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		local := file
+		if archive, _, ok := splitZipEntry(file); ok {
+			local = archive
+		}
+		info, err := os.Stat(local)
+		if err != nil {
+			continue
+		}
+		state := fileState{size: info.Size(), mod: info.ModTime()}
+		if prev, ok := states[file]; !ok || prev.size != state.size || !prev.mod.Equal(state.mod) {
+			states[file] = state
+			continue
+		}
+		delete(states, file)
+		seen[file] = true
+		ready = append(ready, file)
+	}
+	return ready
+}
@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSettled checks that a new file is only picked up once a poll finds
+// it unchanged since the previous one, and only once.
+func TestSettled(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "RC_2024-01.zst")
+	if err := os.WriteFile(file, []byte("part"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{file}
+	seen := make(map[string]bool)
+	states := make(map[string]fileState)
+
+	if ready := settled(files, seen, states); len(ready) != 0 {
+		t.Fatalf("first poll returned %v, want nothing", ready)
+	}
+
+	// Still being written: the size changed.
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("more"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ready := settled(files, seen, states); len(ready) != 0 {
+		t.Fatalf("poll after a write returned %v, want nothing", ready)
+	}
+
+	// Rewritten in place with the same size.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if ready := settled(files, seen, states); len(ready) != 0 {
+		t.Fatalf("poll after a touch returned %v, want nothing", ready)
+	}
+
+	if ready := settled(files, seen, states); !slices.Equal(ready, files) {
+		t.Fatalf("poll of an unchanged file returned %v, want %v", ready, files)
+	}
+	if ready := settled(files, seen, states); len(ready) != 0 {
+		t.Errorf("file returned again: %v", ready)
+	}
+
+	missing := []string{filepath.Join(t.TempDir(), "RC_gone.zst")}
+	for range 2 {
+		if ready := settled(missing, seen, states); len(ready) != 0 {
+			t.Errorf("missing file returned: %v", ready)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright (c) 2025 The R-Proc Contributors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rproc
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// zipSep separates a zip archive from the name of one of its entries in
+// the input path of the entry, as in dump.zip!2023/RC_2023-01.ndjson.
+const zipSep = "!"
+
+// isZip reports whether name is a zip archive, judging by its extension.
+func isZip(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// splitZipEntry splits the input path of a zip archive entry into the
+// path of the archive and the name of the entry.
+func splitZipEntry(input string) (archive, entry string, ok bool) {
+	if isURL(input) {
+		return "", "", false
+	}
+	i := strings.Index(strings.ToLower(input), ".zip"+zipSep)
+	if i < 0 {
+		return "", "", false
+	}
+	return input[:i+len(".zip")], input[i+len(".zip"+zipSep):], true
+}
+
+// zipArchives keeps the zip archives read during a run open, so that the
+// central directory of each is only read once and its entries can be
+// opened concurrently.
+type zipArchives struct {
+	mu      sync.Mutex
+	readers map[string]*zip.ReadCloser
+	entries map[string]map[string]*zip.File
+}
+
+// archive returns the entries of the zip archive at path by name, opening
+// it on first use.
+func (z *zipArchives) archive(path string) (*zip.ReadCloser, map[string]*zip.File, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if r, ok := z.readers[path]; ok {
+		return r, z.entries[path], nil
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	if z.readers == nil {
+		z.readers = make(map[string]*zip.ReadCloser)
+		z.entries = make(map[string]map[string]*zip.File)
+	}
+	z.readers[path] = r
+	z.entries[path] = entries
+	return r, entries, nil
+}
+
+func (z *zipArchives) close() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, r := range z.readers {
+		r.Close()
+	}
+	z.readers = nil
+	z.entries = nil
+}
+
+// zipEntry returns the entry of an archive named by its input path.
+func (p *Processor) zipEntry(archive, name string) (*zip.File, error) {
+	_, entries, err := p.zips.archive(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: archive + zipSep + name, Err: os.ErrNotExist}
+	}
+	return f, nil
+}
+
+// zipInputs returns the input paths of the files in archive that have one
+// of the Extensions and whose base name matches FileFilter.
+func (p *Processor) zipInputs(archive string) ([]string, error) {
+	r, _, err := p.zips.archive(archive)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip archive %s: %w", archive, err)
+	}
+	var inputs []string
+	for _, f := range r.File {
+		name := path.Base(f.Name)
+		if f.FileInfo().IsDir() || !p.hasExtension(name) || !p.FileFilter.MatchString(name) {
+			continue
+		}
+		inputs = append(inputs, archive+zipSep+f.Name)
+	}
+	return inputs, nil
+}
+
+// openZipEntry opens an archive entry for reading. The size is that of the
+// uncompressed entry, which is what the returned reader yields.
+func (p *Processor) openZipEntry(archive, name string) (io.ReadCloser, int64, error) {
+	f, err := p.zipEntry(archive, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+	return rc, int64(f.UncompressedSize64), nil
+}
+
+// inputSize returns the size of a local input file or archive entry as it
+// will be read.
+func (p *Processor) inputSize(input string) (int64, error) {
+	if archive, name, ok := splitZipEntry(input); ok {
+		f, err := p.zipEntry(archive, name)
+		if err != nil {
+			return 0, err
+		}
+		return int64(f.UncompressedSize64), nil
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}